@@ -0,0 +1,114 @@
+package ini
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Function converting a value to a given type, see Decoder.Converter
+type ConverterFunc func(value string) (interface{}, error)
+
+// A size in bytes, decoded from values such as 512, 128K, 128M or 2G
+type ByteSize int64
+
+// Byte size units
+const (
+	Byte     ByteSize = 1
+	KiloByte          = 1024 * Byte
+	MegaByte          = 1024 * KiloByte
+	GigaByte          = 1024 * MegaByte
+	TeraByte          = 1024 * GigaByte
+)
+
+var byteSizeUnits = []struct {
+	suffix string
+	size   ByteSize
+}{
+	{"T", TeraByte},
+	{"G", GigaByte},
+	{"M", MegaByte},
+	{"K", KiloByte},
+}
+
+// Parse a byte size with an optional K, M, G or T suffix,
+// optionally followed by B, as in 128M or 128MB
+func ParseByteSize(value string) (ByteSize, error) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	if len(s) > 1 && strings.HasSuffix(s, "B") {
+		s = s[:len(s)-1]
+	}
+	unit := Byte
+	for _, u := range byteSizeUnits {
+		if strings.HasSuffix(s, u.suffix) {
+			s, unit = strings.TrimSpace(s[:len(s)-1]), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n*int64(unit)/int64(unit) != n {
+		return 0, fmt.Errorf("Invalid byte size %s.", value)
+	}
+	return ByteSize(n) * unit, nil
+}
+
+// Returns the size with the largest exact unit, as in 128M
+func (b ByteSize) String() string {
+	for _, u := range byteSizeUnits {
+		if b != 0 && b%u.size == 0 {
+			return strconv.FormatInt(int64(b/u.size), 10) + u.suffix
+		}
+	}
+	return strconv.FormatInt(int64(b), 10)
+}
+
+// Parse a boolean, accepting on/off and yes/no
+// as well as the values accepted by strconv.ParseBool
+func ParseBool(value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "1", "t", "true", "on", "yes", "y":
+		return true, nil
+	case "0", "f", "false", "off", "no", "n":
+		return false, nil
+	}
+	return false, fmt.Errorf("Invalid boolean %s.", value)
+}
+
+// Parse a duration as accepted by time.ParseDuration.
+// Numbers without unit are taken as seconds
+func ParseDuration(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if n, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(n * float64(time.Second)), nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid duration %s.", value)
+	}
+	return d, nil
+}
+
+var byteSizeType = reflect.TypeOf(ByteSize(0))
+
+var builtinConverters = map[reflect.Type]ConverterFunc{
+	durationType: func(value string) (interface{}, error) {
+		return ParseDuration(value)
+	},
+	byteSizeType: func(value string) (interface{}, error) {
+		return ParseByteSize(value)
+	},
+}
+
+// Splits a comma separated list, trimming the spaces around items
+func splitList(value string) []string {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	items := strings.Split(value, ",")
+	for i, item := range items {
+		items[i] = strings.TrimSpace(item)
+	}
+	return items
+}
@@ -0,0 +1,75 @@
+package ini
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"time"
+)
+
+var _ = Describe("convert", func() {
+	Describe("ParseByteSize", func() {
+		It("should parse sizes with units", func() {
+			sizes := map[string]ByteSize{
+				"512":   512,
+				"2K":    2 * KiloByte,
+				"128M":  128 * MegaByte,
+				"128mb": 128 * MegaByte,
+				"1 G":   GigaByte,
+				"3T":    3 * TeraByte,
+				"-1":    -1,
+			}
+			for value, expected := range sizes {
+				size, err := ParseByteSize(value)
+				Expect(err).To(BeNil())
+				Expect(size).To(Equal(expected))
+			}
+		})
+
+		It("should fail on invalid sizes", func() {
+			for _, value := range []string{"", "M", "12X", "1.5M", "99999999999T"} {
+				_, err := ParseByteSize(value)
+				Expect(err).NotTo(BeNil())
+			}
+		})
+	})
+
+	Describe("ByteSize.String", func() {
+		It("should use the largest exact unit", func() {
+			Expect((128 * MegaByte).String()).To(Equal("128M"))
+			Expect((1536 * KiloByte).String()).To(Equal("1536K"))
+			Expect(ByteSize(1000).String()).To(Equal("1000"))
+			Expect(ByteSize(0).String()).To(Equal("0"))
+		})
+	})
+
+	Describe("ParseBool", func() {
+		It("should accept on, off, yes and no", func() {
+			for _, value := range []string{"On", "yes", "1", "TRUE"} {
+				Expect(ParseBool(value)).To(BeTrue())
+			}
+			for _, value := range []string{"Off", "no", "0", "false"} {
+				Expect(ParseBool(value)).To(BeFalse())
+			}
+			_, err := ParseBool("maybe")
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	Describe("ParseDuration", func() {
+		It("should parse durations and seconds", func() {
+			Expect(ParseDuration("1m30s")).To(Equal(90 * time.Second))
+			Expect(ParseDuration("30")).To(Equal(30 * time.Second))
+			Expect(ParseDuration("0.5")).To(Equal(500 * time.Millisecond))
+			_, err := ParseDuration("soon")
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	Describe("splitList", func() {
+		It("should split and trim items", func() {
+			Expect(splitList(" a, b ,c")).To(Equal([]string{"a", "b", "c"}))
+			Expect(splitList("  ")).To(BeNil())
+		})
+	})
+})
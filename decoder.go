@@ -2,9 +2,12 @@
 package ini
 
 import (
-	"github.com/mitchellh/mapstructure"
+	"errors"
 	"io"
+	"io/fs"
 	"os"
+	"reflect"
+	"strings"
 )
 
 // Alias for map[string]map[string]string
@@ -12,34 +15,172 @@ type Config config
 
 // Struct to parse .ini format from an io.reader
 type Decoder struct {
-	rd      io.Reader
-	options Options
+	rd        io.Reader
+	names     []string
+	options   Options
+	positions map[string]map[string]Position
 }
 
+// Kinds of multi-line values, see Options.Continuation
+type Continuation int
+
+const (
+	// Values do not continue on the next line
+	NoContinuation Continuation = 0
+	// A value ending with a backslash continues on the next line
+	BackslashContinuation Continuation = 1
+	// Lines following a value, indented more deeply than its key, continue it
+	IndentContinuation Continuation = 2
+)
+
+// How keys given several times in a section are decoded,
+// see Options.DuplicateKeys. Array keys are always kept as lists
+type DuplicateKeyPolicy int
+
+const (
+	// All the values are kept, to be decoded into slices.
+	// Other types get the last value
+	DuplicateKeyList DuplicateKeyPolicy = 0
+	// The last value replaces the previous ones
+	DuplicateKeyLast DuplicateKeyPolicy = 1
+	// The first value is kept and the following ones are ignored
+	DuplicateKeyFirst DuplicateKeyPolicy = 2
+	// Duplicate keys are a parse error
+	DuplicateKeyError DuplicateKeyPolicy = 3
+)
+
+// How the values of a file override the ones of the previous files,
+// see Options.FileOverride
+type FileOverridePolicy int
+
+const (
+	// Keys override the keys of the same name
+	FileOverrideKeys FileOverridePolicy = 0
+	// Sections replace the sections of the same name with all their keys
+	FileOverrideSections FileOverridePolicy = 1
+)
+
+// How sections given several times are decoded, see Options.DuplicateSections
+type DuplicateSectionPolicy int
+
+const (
+	// The keys of all the occurrences are merged
+	DuplicateSectionMerge DuplicateSectionPolicy = 0
+	// The last occurrence replaces the previous ones
+	DuplicateSectionLast DuplicateSectionPolicy = 1
+	// The first occurrence is kept and the following ones are ignored
+	DuplicateSectionFirst DuplicateSectionPolicy = 2
+	// Duplicate sections are a parse error
+	DuplicateSectionError DuplicateSectionPolicy = 3
+	// Each occurrence is kept separately, to be decoded into a slice
+	// of structs or maps. Other types get the merged keys
+	DuplicateSectionRepeat DuplicateSectionPolicy = 4
+)
+
+// What to do with environment variables which are not set,
+// see Options.MissingEnv
+type MissingEnvPolicy int
+
+const (
+	// Missing variables are a decode error
+	MissingEnvError MissingEnvPolicy = 0
+	// Missing variables are replaced by an empty string
+	MissingEnvEmpty MissingEnvPolicy = 1
+	// References to missing variables are kept as written
+	MissingEnvVerbatim MissingEnvPolicy = 2
+)
+
 // Struct to contain options for ini.Decoder
 type Options struct {
-	IdRegexp     string
-	SepChars     []byte
-	CommentChars []byte
-	LowCaseIds   bool
+	IdRegexp            string
+	SepChars            []byte
+	CommentChars        []byte
+	LowCaseIds          bool
+	Strict              bool
+	DisallowUnknownKeys bool
+	AllowGlobalKeys     bool
+	Converters          map[reflect.Type]ConverterFunc
+	Continuation        Continuation
+	ContinuationJoin    string
+	DuplicateKeys       DuplicateKeyPolicy
+	DuplicateSections   DuplicateSectionPolicy
+	SectionInheritance  bool
+	DefaultSection      string
+	Interpolation       bool
+	EnvExpansion        bool
+	LookupEnv           func(name string) (string, bool)
+	MissingEnv          MissingEnvPolicy
+	EnvOverrides        bool
+	EnvPrefix           string
+	EnvName             func(prefix string, section string, key string) string
+	Includes            bool
+	IncludeKey          string
+	MaxIncludeDepth     int
+	FS                  fs.FS
+	SkipMissingFiles    bool
+	FileOverride        FileOverridePolicy
+	RecoverErrors       bool
+	MaxErrors           int
+	TabWidth            int
+	DetectUTF16         bool
 }
 
 // Default options for ini.Decoder
 var DefaultOptions Options = Options{
-	IdRegexp:     idDefaultRegex,
-	SepChars:     []byte{'='},
-	CommentChars: []byte{';'},
-	LowCaseIds:   true,
+	IdRegexp:            idDefaultRegex,
+	SepChars:            []byte{'='},
+	CommentChars:        []byte{';'},
+	LowCaseIds:          true,
+	Strict:              false,
+	DisallowUnknownKeys: false,
+	AllowGlobalKeys:     false,
+	Converters:          nil,
+	Continuation:        NoContinuation,
+	ContinuationJoin:    "\n",
+	DuplicateKeys:       DuplicateKeyList,
+	DuplicateSections:   DuplicateSectionMerge,
+	SectionInheritance:  false,
+	DefaultSection:      "",
+	Interpolation:       false,
+	EnvExpansion:        false,
+	LookupEnv:           os.LookupEnv,
+	MissingEnv:          MissingEnvError,
+	EnvOverrides:        false,
+	EnvPrefix:           "",
+	EnvName:             EnvVarName,
+	Includes:            false,
+	IncludeKey:          "",
+	MaxIncludeDepth:     10,
+	FS:                  nil,
+	SkipMissingFiles:    false,
+	FileOverride:        FileOverrideKeys,
+	RecoverErrors:       false,
+	MaxErrors:           10,
+	TabWidth:            1,
+	DetectUTF16:         false,
 }
 
 // Creates a new ini.Decoder from an io.Reader
 func NewDecoder(rd io.Reader) *Decoder {
-	return &Decoder{rd, DefaultOptions}
+	return &Decoder{rd: rd, options: DefaultOptions}
 }
 
 // Creates a new ini.Decoder from an io.Reader with custom options
 func NewDecoderWithOptions(rd io.Reader, opts Options) *Decoder {
-	return &Decoder{rd, opts}
+	return &Decoder{rd: rd, options: opts}
+}
+
+// Creates a new ini.Decoder reading the named file, from the file system
+// set with FS or else from the OS. Included files are resolved
+// relative to it, and parse errors name the file they occur in
+func NewFileDecoder(name string) *Decoder {
+	return &Decoder{names: []string{name}, options: DefaultOptions}
+}
+
+// Creates a new ini.Decoder reading the named files in order,
+// the values of each file overriding the ones of the previous files
+func NewFilesDecoder(names ...string) *Decoder {
+	return &Decoder{names: names, options: DefaultOptions}
 }
 
 // Set the separator characters between keys and values. Defaults to '='
@@ -52,7 +193,8 @@ func (d *Decoder) CommentChars(commentChars []byte) {
 	d.options.CommentChars = commentChars
 }
 
-// Set if the keys should be converted to lower case. Defaults to true.
+// Set if the keys should be converted to lower case, folding the case
+// of Unicode letters. Defaults to true.
 func (d *Decoder) LowCaseIds(lowCaseIds bool) {
 	d.options.LowCaseIds = lowCaseIds
 }
@@ -62,27 +204,307 @@ func (d *Decoder) IdRegexp(idRegexp string) {
 	d.options.IdRegexp = idRegexp
 }
 
-// Decode the io.Reader contained into the given interface.
-// Returns an error on failure
+// Set if values should be converted strictly to the type of the field
+// they are decoded into, without turning empty values into zero values
+// or truncating decimal numbers. Defaults to false.
+func (d *Decoder) Strict(strict bool) {
+	d.options.Strict = strict
+}
+
+// Set if keys and sections without a matching struct field
+// should return an error. Defaults to false.
+func (d *Decoder) DisallowUnknownKeys(disallow bool) {
+	d.options.DisallowUnknownKeys = disallow
+}
+
+// Set if keys are allowed before the first section. They are decoded
+// into the section named "" of a map, or into the fields of a struct
+// which are not sections. Defaults to false.
+func (d *Decoder) AllowGlobalKeys(allow bool) {
+	d.options.AllowGlobalKeys = allow
+}
+
+// Set the function converting values decoded into the given type.
+// It takes precedence over the built-in conversions, which handle
+// time.Duration, ini.ByteSize, booleans such as On or Off,
+// and slices given as comma separated lists.
+func (d *Decoder) Converter(t reflect.Type, fn ConverterFunc) {
+	converters := make(map[reflect.Type]ConverterFunc, len(d.options.Converters)+1)
+	for typ, convert := range d.options.Converters {
+		converters[typ] = convert
+	}
+	converters[t] = fn
+	d.options.Converters = converters
+}
+
+// Set how values continue on the following lines, either
+// ini.BackslashContinuation, ini.IndentContinuation or both.
+// Defaults to ini.NoContinuation.
+func (d *Decoder) Continuation(continuation Continuation) {
+	d.options.Continuation = continuation
+}
+
+// Set the string joining the lines of a multi-line value. Defaults to "\n".
+func (d *Decoder) ContinuationJoin(join string) {
+	d.options.ContinuationJoin = join
+}
+
+// Set how keys given several times in a section are decoded.
+// Defaults to ini.DuplicateKeyList.
+func (d *Decoder) DuplicateKeys(policy DuplicateKeyPolicy) {
+	d.options.DuplicateKeys = policy
+}
+
+// Set how sections given several times are decoded.
+// Defaults to ini.DuplicateSectionMerge.
+func (d *Decoder) DuplicateSections(policy DuplicateSectionPolicy) {
+	d.options.DuplicateSections = policy
+}
+
+// Set if sections can inherit the keys of another section
+// with the [child : parent] syntax. Defaults to false.
+func (d *Decoder) SectionInheritance(inheritance bool) {
+	d.options.SectionInheritance = inheritance
+}
+
+// Set the name of the section, such as DEFAULT, whose keys are inherited
+// by all the other sections. Defaults to "", for no default section.
+func (d *Decoder) DefaultSection(name string) {
+	d.options.DefaultSection = name
+}
+
+// Set if references to other keys, written ${section.key}, ${key}
+// or %(key)s, should be replaced by their values. Defaults to false.
+func (d *Decoder) Interpolation(interpolation bool) {
+	d.options.Interpolation = interpolation
+}
+
+// Set if references to environment variables, written ${ENV:NAME}
+// or ${NAME:-default}, should be replaced by their values. The default
+// is used when the variable is not set or empty. Defaults to false.
+func (d *Decoder) EnvExpansion(expansion bool) {
+	d.options.EnvExpansion = expansion
+}
+
+// Set the function looking up environment variables. Defaults to os.LookupEnv
+func (d *Decoder) LookupEnv(lookup func(name string) (string, bool)) {
+	d.options.LookupEnv = lookup
+}
+
+// Set what to do with environment variables which are not set and
+// have no default. Defaults to ini.MissingEnvError.
+func (d *Decoder) MissingEnv(policy MissingEnvPolicy) {
+	d.options.MissingEnv = policy
+}
+
+// Set if environment variables named after the keys, such as
+// PREFIX_SECTION_KEY, should replace the values of the file.
+// The keys of the struct decoded into are looked up even when
+// missing from the file. Overriding values are not interpolated.
+// An EnvPrefix is required, so that variables such as HOME or USER
+// do not override the keys sharing their name. Defaults to false.
+func (d *Decoder) EnvOverrides(overrides bool) {
+	d.options.EnvOverrides = overrides
+}
+
+// Set the prefix of the environment variables overriding keys,
+// required with EnvOverrides. Defaults to ""
+func (d *Decoder) EnvPrefix(prefix string) {
+	d.options.EnvPrefix = prefix
+}
+
+// Set the function returning the name of the environment variable
+// overriding a key. Defaults to ini.EnvVarName
+func (d *Decoder) EnvName(name func(prefix string, section string, key string) string) {
+	d.options.EnvName = name
+}
+
+// Set if files can be included with the !include path directive,
+// where path can be a glob pattern. Defaults to false.
+func (d *Decoder) Includes(includes bool) {
+	d.options.Includes = includes
+}
+
+// Set the name of the key, such as include, whose values are
+// included files. Defaults to "", for no such key.
+func (d *Decoder) IncludeKey(key string) {
+	d.options.IncludeKey = key
+}
+
+// Set how deeply included files can include other files. Defaults to 10.
+func (d *Decoder) MaxIncludeDepth(depth int) {
+	d.options.MaxIncludeDepth = depth
+}
+
+// Set the file system files are read from. Defaults to nil, for the OS
+func (d *Decoder) FS(fsys fs.FS) {
+	d.options.FS = fsys
+}
+
+// Set if files which do not exist should be skipped
+// rather than returning an error. Defaults to false.
+func (d *Decoder) SkipMissingFiles(skip bool) {
+	d.options.SkipMissingFiles = skip
+}
+
+// Set how the values of a file override the ones of the previous files.
+// Defaults to ini.FileOverrideKeys.
+func (d *Decoder) FileOverride(policy FileOverridePolicy) {
+	d.options.FileOverride = policy
+}
+
+// Set if parsing should go on after a syntax error, skipping the line
+// it occurs on. Decode then returns all the syntax errors, joined with
+// errors.Join, along with the values it could decode. Defaults to false.
+func (d *Decoder) RecoverErrors(recover bool) {
+	d.options.RecoverErrors = recover
+}
+
+// Set the number of syntax errors collected when recovering from errors,
+// parsing stopping at the next one, 0 for no limit. Defaults to 10.
+func (d *Decoder) MaxErrors(max int) {
+	d.options.MaxErrors = max
+}
+
+// Set the number of columns a tab moves to, up to the next multiple
+// of the width, in the columns of errors and positions. Columns count
+// characters rather than bytes. Defaults to 1.
+func (d *Decoder) TabWidth(width int) {
+	d.options.TabWidth = width
+}
+
+// Set if UTF-16 content should be detected and read, as written by
+// some Windows editors. UTF-8 byte order marks are always skipped.
+// Defaults to false.
+func (d *Decoder) DetectUTF16(detect bool) {
+	d.options.DetectUTF16 = detect
+}
+
+// Returns the file the value of a key was last read from by Decode,
+// or the environment variable overriding it, written $NAME.
+// Returns "" for unknown keys and values read from an io.Reader
+func (d *Decoder) Source(section string, key string) string {
+	pos, _ := d.Position(section, key)
+	return pos.File
+}
+
+// Returns where the value of a key was last read from by Decode,
+// through includes, inheritance and layered files.
+// Returns false for unknown keys
+func (d *Decoder) Position(section string, key string) (Position, bool) {
+	if d.options.LowCaseIds {
+		section, key = foldCase(section), foldCase(key)
+	}
+	pos, ok := d.positions[section][key]
+	return pos, ok
+}
+
+// Parses the io.Reader, or the named files in order. Included files are
+// only read if loadIncludes is set, otherwise their directive is kept as is.
+// When recovering from errors, the parser is returned with the syntax errors
+func (d *Decoder) parse(loadIncludes bool) (*parser, error) {
+	rd := d.rd
+	if rd == nil {
+		rd = strings.NewReader("")
+	}
+	pars := newParserFromOptions(rd, d.options)
+	fsys := pars.fsys
+	if !loadIncludes {
+		pars.fsys = nil
+	}
+	err := pars.parseConfig()
+	for i := 0; i < len(d.names) && err == nil; i++ {
+		err = d.parseFile(pars, fsys, d.names[i])
+	}
+	if err != nil && err != errTooManyErrors {
+		return nil, err
+	}
+	return pars, pars.collectedErrors(err)
+}
+
+func (d *Decoder) parseFile(pars *parser, fsys fs.FS, name string) error {
+	file, err := fsys.Open(name)
+	if err != nil {
+		if d.options.SkipMissingFiles && errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+	return pars.parseLayer(file, name)
+}
+
+// Decode the io.Reader contained into the given interface, which must be
+// a pointer to a map or a struct. Sections are decoded into the struct
+// field with the same name, matched ignoring case and underscores,
+// or to the name given with the `ini:"name"` tag. Keys given several times
+// or with the key[] syntax are decoded into slices with one item per value.
+// Returns an error on failure, a *DecodeError if a value is invalid
 func (d *Decoder) Decode(r interface{}) error {
-	pars := newParserWithOptions(d.rd,
-		d.options.IdRegexp, d.options.LowCaseIds,
-		d.options.SepChars, d.options.CommentChars)
-	if err := pars.parseConfig(); err != nil {
+	pars, syntaxErr := d.parse(true)
+	if pars == nil {
+		return syntaxErr
+	}
+	if err := d.decode(pars, r); err != nil {
+		if syntaxErr != nil {
+			return errors.Join(syntaxErr, err)
+		}
 		return err
 	}
-	if err := mapstructure.WeakDecode(pars.currentConfig, r); err != nil {
+	return syntaxErr
+}
+
+func (d *Decoder) decode(pars *parser, r interface{}) error {
+	if err := pars.resolveInheritance(); err != nil {
 		return err
 	}
-	return nil
+	if d.options.EnvOverrides {
+		if err := pars.overrideFromEnv(d.options, r); err != nil {
+			return err
+		}
+	}
+	if d.options.Interpolation || d.options.EnvExpansion {
+		if err := pars.interpolate(d.options); err != nil {
+			return err
+		}
+	}
+	d.positions = pars.positions
+	u := &unmarshaler{
+		strict:          d.options.Strict,
+		disallowUnknown: d.options.DisallowUnknownKeys,
+		converters:      d.options.Converters,
+		lists:           pars.currentLists,
+		repeated:        pars.repeatedSections,
+		paths:           pars.sectionPaths,
+		defaultSection:  pars.defaultSection,
+	}
+	return u.unmarshal(pars.currentConfig, r)
 }
 
+// Decode the io.Reader contained into an ini.Document, which keeps
+// comments, ordering and spacing. Returns an error on failure, and for
+// a decoder of several files, which cannot be written back as one
+func (d *Decoder) DecodeDocument() (*Document, error) {
+	if len(d.names) > 1 {
+		return nil, errSeveralFiles
+	}
+	pars, err := d.parse(false)
+	if pars == nil {
+		return nil, err
+	}
+	return pars.doc.finish(), err
+}
+
+// Error returned by DecodeDocument for a decoder of several files
+var errSeveralFiles = errors.New("Cannot decode several files into a document, decode each file instead.")
+
 // Decode the given file to the given interface
 func DecodeFile(path string, v interface{}) error {
-	file, err := os.Open(path)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-	return NewDecoder(file).Decode(v)
+	return NewFileDecoder(path).Decode(v)
+}
+
+// Decode the given files to the given interface, the values
+// of each file overriding the ones of the previous files
+func DecodeFiles(v interface{}, paths ...string) error {
+	return NewFilesDecoder(paths...).Decode(v)
 }
@@ -5,6 +5,7 @@ import (
 	. "github.com/onsi/gomega"
 
 	"os"
+	"reflect"
 	"strings"
 )
 
@@ -43,6 +44,244 @@ var _ = Describe("Decoder", func() {
 			Expect(c.Section.Foo).To(Equal("bar"))
 		})
 
+		It("should decode to struct with tags", func() {
+			type section struct {
+				Value int `ini:"foo"`
+			}
+			d := NewDecoder(strings.NewReader("[section]\nfoo = 12\n"))
+			var c struct {
+				Section section `ini:"section"`
+			}
+			err := d.Decode(&c)
+			Expect(err).To(BeNil())
+			Expect(c.Section.Value).To(Equal(12))
+		})
+
+		It("should use strict mode when asked to", func() {
+			var c struct {
+				Section struct{ Foo int }
+			}
+			d := NewDecoder(strings.NewReader("[section]\nfoo = 1.5\n"))
+			Expect(d.Decode(&c)).To(BeNil())
+			Expect(c.Section.Foo).To(Equal(1))
+			d = NewDecoder(strings.NewReader("[section]\nfoo = 1.5\n"))
+			d.Strict(true)
+			err := d.Decode(&c)
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring("[section] foo"))
+		})
+
+		It("should fail on unknown keys when asked to", func() {
+			var c conf
+			d := NewDecoder(strings.NewReader("[section]\nfoo = bar\nbaz = qux\n"))
+			d.DisallowUnknownKeys(true)
+			err := d.Decode(&c)
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring("Unknown key"))
+		})
+
+		It("should use the given converters", func() {
+			var c struct {
+				Section struct{ Foo []string }
+			}
+			d := NewDecoder(strings.NewReader("[section]\nfoo = a b c\n"))
+			d.Converter(reflect.TypeOf([]string{}), func(value string) (interface{}, error) {
+				return strings.Fields(value), nil
+			})
+			err := d.Decode(&c)
+			Expect(err).To(BeNil())
+			Expect(c.Section.Foo).To(Equal([]string{"a", "b", "c"}))
+		})
+
+		It("should decode global keys when allowed", func() {
+			content := "name = app\n[section]\nfoo = bar\n"
+			var c Config
+			err := NewDecoder(strings.NewReader(content)).Decode(&c)
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring("Expected section start"))
+			d := NewDecoder(strings.NewReader(content))
+			d.AllowGlobalKeys(true)
+			err = d.Decode(&c)
+			Expect(err).To(BeNil())
+			Expect(c).To(Equal(Config{"": {"name": "app"}, "section": {"foo": "bar"}}))
+			var s struct {
+				Name    string
+				Section sectionConf
+			}
+			d = NewDecoder(strings.NewReader(content))
+			d.AllowGlobalKeys(true)
+			err = d.Decode(&s)
+			Expect(err).To(BeNil())
+			Expect(s.Name).To(Equal("app"))
+			Expect(s.Section.Foo).To(Equal("bar"))
+		})
+
+		It("should decode array and repeated keys into slices", func() {
+			content := "[php]\nextension[] = curl\nextension[] = gd,mb\nport = 80\nport = 443\n" +
+				"single[] = a,b\nlist = a,b\nlast = 1\nlast = 2\n"
+			var s struct {
+				PHP struct {
+					Extension []string
+					Port      []int
+					Single    []string
+					List      []string
+					Last      int
+				} `ini:"php"`
+			}
+			err := NewDecoder(strings.NewReader(content)).Decode(&s)
+			Expect(err).To(BeNil())
+			Expect(s.PHP.Extension).To(Equal([]string{"curl", "gd,mb"}))
+			Expect(s.PHP.Port).To(Equal([]int{80, 443}))
+			Expect(s.PHP.Single).To(Equal([]string{"a,b"}))
+			Expect(s.PHP.List).To(Equal([]string{"a", "b"}))
+			Expect(s.PHP.Last).To(Equal(2))
+			var m map[string]map[string][]string
+			err = NewDecoder(strings.NewReader(content)).Decode(&m)
+			Expect(err).To(BeNil())
+			Expect(m["php"]["extension"]).To(Equal([]string{"curl", "gd,mb"}))
+			var c Config
+			err = NewDecoder(strings.NewReader(content)).Decode(&c)
+			Expect(err).To(BeNil())
+			Expect(c["php"]["extension"]).To(Equal("gd,mb"))
+		})
+
+		It("should decode repeated sections into slices", func() {
+			type remote struct {
+				Name string
+				URL  string `ini:"url"`
+			}
+			content := "[remote]\nname = origin\nurl = a\n[remote]\nname = upstream\nurl = b\n[single]\nname = c\n"
+			var s struct {
+				Remote []remote
+				Single []*remote
+			}
+			d := NewDecoder(strings.NewReader(content))
+			d.DuplicateSections(DuplicateSectionRepeat)
+			d.DuplicateKeys(DuplicateKeyError)
+			err := d.Decode(&s)
+			Expect(err).To(BeNil())
+			Expect(s.Remote).To(Equal([]remote{{"origin", "a"}, {"upstream", "b"}}))
+			Expect(s.Single).To(Equal([]*remote{{Name: "c"}}))
+			d = NewDecoder(strings.NewReader(content))
+			d.DuplicateKeys(DuplicateKeyError)
+			err = d.Decode(&s)
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring("Duplicate key name, first defined at line 2."))
+		})
+
+		It("should decode nested sections into nested structs and maps", func() {
+			type listener struct {
+				Port int `ini:"port"`
+			}
+			type remote struct {
+				URL string `ini:"url"`
+			}
+			type config struct {
+				Server struct {
+					Host string    `ini:"host"`
+					HTTP listener  `ini:"http"`
+					GRPC *listener `ini:"grpc"`
+				} `ini:"server"`
+				Remote map[string]remote `ini:"remote"`
+			}
+			content := "[server]\nhost = localhost\n[server.http]\nport = 80\n[server.grpc]\nport = 90\n" +
+				"[remote \"origin\"]\nurl = a\n[remote \"up.stream\"]\nurl = b\n"
+			var c config
+			err := NewDecoder(strings.NewReader(content)).Decode(&c)
+			Expect(err).To(BeNil())
+			Expect(c.Server.Host).To(Equal("localhost"))
+			Expect(c.Server.HTTP.Port).To(Equal(80))
+			Expect(c.Server.GRPC.Port).To(Equal(90))
+			Expect(c.Remote).To(Equal(map[string]remote{"origin": {"a"}, "up.stream": {"b"}}))
+			var m Config
+			err = NewDecoder(strings.NewReader(content)).Decode(&m)
+			Expect(err).To(BeNil())
+			Expect(m["remote.up.stream"]).To(Equal(map[string]string{"url": "b"}))
+			d := NewDecoder(strings.NewReader("[server.nope]\nport = 80\n"))
+			d.DisallowUnknownKeys(true)
+			err = d.Decode(&c)
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring("Unknown section"))
+		})
+
+		It("should resolve inherited sections and default keys", func() {
+			content := "[DEFAULT]\nlog = info\n[production]\nhost = prod\ndebug = off\nextra[] = a\n" +
+				"[staging : production]\nhost = staging\n[dev : staging]\ndebug = on\nlog = debug\n"
+			d := NewDecoder(strings.NewReader(content))
+			d.SectionInheritance(true)
+			d.DefaultSection("DEFAULT")
+			var c Config
+			err := d.Decode(&c)
+			Expect(err).To(BeNil())
+			Expect(c["staging"]).To(Equal(map[string]string{"host": "staging", "debug": "off", "extra": "a", "log": "info"}))
+			Expect(c["dev"]).To(Equal(map[string]string{"host": "staging", "debug": "on", "extra": "a", "log": "debug"}))
+			var s struct {
+				Dev struct {
+					Host  string
+					Debug bool
+					Extra []string
+				}
+			}
+			d = NewDecoder(strings.NewReader(content))
+			d.SectionInheritance(true)
+			d.DefaultSection("default")
+			err = d.Decode(&s)
+			Expect(err).To(BeNil())
+			Expect(s.Dev.Host).To(Equal("staging"))
+			Expect(s.Dev.Debug).To(BeTrue())
+			Expect(s.Dev.Extra).To(Equal([]string{"a"}))
+		})
+
+		It("should fail on inheritance loops and unknown parents", func() {
+			d := NewDecoder(strings.NewReader("[aa : bb]\n[bb : cc]\n[cc : bb]\n"))
+			d.SectionInheritance(true)
+			var c Config
+			err := d.Decode(&c)
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(Equal("Decode error at [bb]. Inheritance loop bb -> cc -> bb."))
+			d = NewDecoder(strings.NewReader("[aa : nope]\n"))
+			d.SectionInheritance(true)
+			err = d.Decode(&c)
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(Equal("Decode error at [aa]. Unknown parent section nope."))
+		})
+
+		It("should interpolate references when asked to", func() {
+			content := "[paths]\nroot = /srv\nlogs = ${root}/logs\n[app]\nlog = ${paths.logs}/app.log\n"
+			var c Config
+			err := NewDecoder(strings.NewReader(content)).Decode(&c)
+			Expect(err).To(BeNil())
+			Expect(c["app"]["log"]).To(Equal("${paths.logs}/app.log"))
+			d := NewDecoder(strings.NewReader(content))
+			d.Interpolation(true)
+			err = d.Decode(&c)
+			Expect(err).To(BeNil())
+			Expect(c["app"]["log"]).To(Equal("/srv/logs/app.log"))
+		})
+
+		It("should expand environment variables when asked to", func() {
+			var c Config
+			d := NewDecoder(strings.NewReader("[db]\npassword = ${ENV:DB_PASSWORD}\nuser = ${DB_USER:-admin}\n"))
+			d.EnvExpansion(true)
+			d.LookupEnv(func(name string) (string, bool) {
+				return "secret", name == "DB_PASSWORD"
+			})
+			err := d.Decode(&c)
+			Expect(err).To(BeNil())
+			Expect(c["db"]).To(Equal(map[string]string{"password": "secret", "user": "admin"}))
+		})
+
+		It("should decode multi-line values", func() {
+			var c Config
+			d := NewDecoder(strings.NewReader("[section]\nfoo = a,\\\n      b\n    c\nbar = d\n"))
+			d.Continuation(BackslashContinuation | IndentContinuation)
+			d.ContinuationJoin("")
+			err := d.Decode(&c)
+			Expect(err).To(BeNil())
+			Expect(c["section"]["foo"]).To(Equal("a,bc"))
+			Expect(c["section"]["bar"]).To(Equal("d"))
+		})
+
 		It("should parse simple files", func() {
 			var c Config
 			err := DecodeFile("./test_data/simple.ini", &c)
@@ -68,6 +307,7 @@ var _ = Describe("Decoder", func() {
 			engine, ok := php["engine"]
 			Expect(ok).To(BeTrue())
 			Expect(engine).To(Equal("On"))
+			Expect(php["variables_order"]).To(Equal("GPCS"))
 		})
 	})
 })
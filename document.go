@@ -0,0 +1,491 @@
+package ini
+
+import (
+	"bytes"
+	"io"
+	"strings"
+)
+
+// A parsed ini file keeping comments, blank lines, ordering
+// and spacing, so that it can be written back unchanged
+type Document struct {
+	sections   []*Section
+	trailing   []string
+	lowCaseIds bool
+	sepChar    byte
+	newLine    string
+	bom        bool
+}
+
+// A section of an ini.Document. The first section of a document
+// has an empty name and holds what comes before the first header
+type Section struct {
+	name     string
+	parent   string
+	comments []string
+	prefix   string
+	rawName  string
+	suffix   string
+	keys     []*Key
+	doc      *Document
+}
+
+// A key of an ini.Section, with its value and the comments preceding it
+type Key struct {
+	name     string
+	value    string
+	comments []string
+	indent   string
+	rawName  string
+	sep      string
+	rawValue string
+	suffix   string
+	array    bool
+	section  *Section
+}
+
+// Parse the given io.Reader into an ini.Document with default options
+func ParseDocument(rd io.Reader) (*Document, error) {
+	return NewDecoder(rd).DecodeDocument()
+}
+
+// Returns all the sections of the document, including the unnamed one
+func (d *Document) Sections() []*Section {
+	return d.sections
+}
+
+// Returns the first section with the given name, or nil if none exists
+func (d *Document) Section(name string) *Section {
+	name = d.normalize(name)
+	for _, s := range d.sections {
+		if s.name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// Returns the comments and blank lines at the end of the document
+func (d *Document) Comments() []string {
+	return trimLines(d.trailing)
+}
+
+// Returns the content of the document as a ini.Config
+func (d *Document) Config() Config {
+	conf := make(Config)
+	for i, s := range d.sections {
+		if i == 0 && len(s.keys) == 0 {
+			continue
+		}
+		values, ok := conf[s.name]
+		if !ok {
+			values = make(map[string]string)
+			conf[s.name] = values
+		}
+		for _, k := range s.keys {
+			values[k.name] = k.value
+		}
+	}
+	return conf
+}
+
+// Returns the section with the given name, appending
+// a new one at the end of the document if none exists
+func (d *Document) AddSection(name string) *Section {
+	if s := d.Section(name); s != nil {
+		return s
+	}
+	s := &Section{doc: d, prefix: "["}
+	s.SetName(name)
+	d.sections = append(d.sections, s)
+	return s
+}
+
+// Delete the first section with the given name, with its keys and comments.
+// Returns false if there is no such section
+func (d *Document) DeleteSection(name string) bool {
+	i := d.sectionIndex(name)
+	if i < 1 {
+		return false
+	}
+	d.sections = append(d.sections[:i], d.sections[i+1:]...)
+	return true
+}
+
+// Rename the first section with the given name.
+// Returns false if there is no such section
+func (d *Document) RenameSection(name string, newName string) bool {
+	i := d.sectionIndex(name)
+	if i < 1 {
+		return false
+	}
+	d.sections[i].SetName(newName)
+	return true
+}
+
+// Move the section with the given name right after the section after.
+// An empty after moves the section before all the other named sections.
+// Returns false if one of the sections does not exist
+func (d *Document) MoveSectionAfter(name string, after string) bool {
+	i := d.sectionIndex(name)
+	if i < 1 || d.sectionIndex(after) < 0 {
+		return false
+	}
+	s := d.sections[i]
+	if s.name == d.normalize(after) {
+		return true
+	}
+	d.sections = append(d.sections[:i], d.sections[i+1:]...)
+	j := d.sectionIndex(after)
+	d.sections = append(d.sections[:j+1], append([]*Section{s}, d.sections[j+1:]...)...)
+	return true
+}
+
+// Write the document to the given io.Writer, starting with
+// the UTF-8 byte order mark of the file it was read from
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	var buffer bytes.Buffer
+	for _, s := range d.sections {
+		s.write(&buffer)
+	}
+	d.writeLines(&buffer, d.trailing)
+	var bom []byte
+	if d.bom {
+		bom = utf8BOM
+	}
+	return io.Copy(w, io.MultiReader(bytes.NewReader(bom), &buffer))
+}
+
+// Returns the document as it would be written by WriteTo
+func (d *Document) String() string {
+	var buffer bytes.Buffer
+	_, _ = d.WriteTo(&buffer)
+	return buffer.String()
+}
+
+func (d *Document) sectionIndex(name string) int {
+	return indexOfSection(d.sections, d.normalize(name))
+}
+
+func indexOfSection(sections []*Section, name string) int {
+	for i, s := range sections {
+		if s.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Writes the given lines, starting a new line first
+// if the previous one had no line ending
+func (d *Document) writeLines(buffer *bytes.Buffer, lines []string) {
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		if buffer.Len() > 0 && lineEnding(buffer.Bytes()) == "" {
+			buffer.WriteString(d.newLine)
+		}
+		buffer.WriteString(line)
+	}
+}
+
+func (d *Document) normalize(name string) string {
+	if d.lowCaseIds {
+		return foldCase(name)
+	}
+	return name
+}
+
+// Returns the name of the section
+func (s *Section) Name() string {
+	return s.name
+}
+
+// Returns the name of the section this section inherits from,
+// as given with the [child : parent] syntax, or ""
+func (s *Section) Parent() string {
+	return s.parent
+}
+
+// Returns the comments and blank lines preceding the section header
+func (s *Section) Comments() []string {
+	return trimLines(s.comments)
+}
+
+// Returns all the keys of the section in order
+func (s *Section) Keys() []*Key {
+	return s.keys
+}
+
+// Returns the last key with the given name, or nil if none exists
+func (s *Section) Key(name string) *Key {
+	if i := s.keyIndex(name); i >= 0 {
+		return s.keys[i]
+	}
+	return nil
+}
+
+// Returns the values of all the keys with the given name in order,
+// as given with the key[] syntax or by repeating the key
+func (s *Section) Values(name string) []string {
+	name = s.doc.normalize(name)
+	var values []string
+	for _, k := range s.keys {
+		if k.name == name {
+			values = append(values, k.value)
+		}
+	}
+	return values
+}
+
+// Rename the section. The rest of the header line is kept as is.
+// The unnamed section at the start of the document cannot be renamed
+func (s *Section) SetName(name string) {
+	if s.prefix == "" {
+		return
+	}
+	s.name = s.doc.normalize(name)
+	s.rawName = name
+	if s.suffix == "" {
+		s.suffix = "]" + s.doc.newLine
+	}
+}
+
+// Set the value of the last key with the given name,
+// appending a new key at the end of the section if none exists
+func (s *Section) Set(name string, value string) *Key {
+	if k := s.Key(name); k != nil {
+		k.SetValue(value)
+		return k
+	}
+	k := s.newKey(name, value)
+	s.keys = append(s.keys, k)
+	return k
+}
+
+// Add a new key with the given name, right after the last key with
+// this name if any, so that the key holds several values
+func (s *Section) Add(name string, value string) *Key {
+	i := s.keyIndex(name)
+	k := s.newKey(name, value)
+	if i < 0 {
+		i = len(s.keys) - 1
+	} else if s.keys[i].array {
+		k.array = true
+		k.SetName(name)
+	}
+	s.keys = append(s.keys[:i+1], append([]*Key{k}, s.keys[i+1:]...)...)
+	return k
+}
+
+// Insert a new key right after the last key named after.
+// An empty after inserts the key at the start of the section.
+// Returns nil if after does not exist
+func (s *Section) InsertAfter(after string, name string, value string) *Key {
+	i := s.keyIndex(after)
+	if i < 0 && after != "" {
+		return nil
+	}
+	k := s.newKey(name, value)
+	s.keys = append(s.keys[:i+1], append([]*Key{k}, s.keys[i+1:]...)...)
+	return k
+}
+
+// Delete all the keys with the given name, with their comments.
+// Returns false if there is no such key
+func (s *Section) Delete(name string) bool {
+	name = s.doc.normalize(name)
+	keys := s.keys[:0]
+	for _, k := range s.keys {
+		if k.name != name {
+			keys = append(keys, k)
+		}
+	}
+	deleted := len(keys) < len(s.keys)
+	s.keys = keys
+	return deleted
+}
+
+// Rename all the keys with the given name.
+// Returns false if there is no such key
+func (s *Section) RenameKey(name string, newName string) bool {
+	name = s.doc.normalize(name)
+	renamed := false
+	for _, k := range s.keys {
+		if k.name == name {
+			k.SetName(newName)
+			renamed = true
+		}
+	}
+	return renamed
+}
+
+// Move the last key with the given name right after the last key named after.
+// An empty after moves the key at the start of the section.
+// Returns false if one of the keys does not exist
+func (s *Section) MoveAfter(name string, after string) bool {
+	i := s.keyIndex(name)
+	if i < 0 || (after != "" && s.keyIndex(after) < 0) {
+		return false
+	}
+	k := s.keys[i]
+	if k.name == s.doc.normalize(after) {
+		return true
+	}
+	s.keys = append(s.keys[:i], s.keys[i+1:]...)
+	j := s.keyIndex(after)
+	s.keys = append(s.keys[:j+1], append([]*Key{k}, s.keys[j+1:]...)...)
+	return true
+}
+
+func (s *Section) keyIndex(name string) int {
+	name = s.doc.normalize(name)
+	for i := len(s.keys) - 1; i >= 0; i-- {
+		if s.keys[i].name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *Section) newKey(name string, value string) *Key {
+	k := &Key{
+		section: s,
+		sep:     " " + string(s.doc.sepChar) + " ",
+		suffix:  s.doc.newLine,
+	}
+	if len(s.keys) > 0 {
+		last := s.keys[len(s.keys)-1]
+		k.indent = last.indent
+		k.sep = last.sep
+	}
+	k.SetName(name)
+	k.SetValue(value)
+	return k
+}
+
+func (s *Section) write(buffer *bytes.Buffer) {
+	s.doc.writeLines(buffer, s.comments)
+	s.doc.writeLines(buffer, []string{s.prefix + s.rawName + s.suffix})
+	for _, k := range s.keys {
+		k.write(buffer)
+	}
+}
+
+// Returns the name of the key
+func (k *Key) Name() string {
+	return k.name
+}
+
+// Returns the value of the key
+func (k *Key) Value() string {
+	return k.value
+}
+
+// Returns the comments and blank lines preceding the key
+func (k *Key) Comments() []string {
+	return trimLines(k.comments)
+}
+
+// Rename the key. The rest of the line is kept as is
+func (k *Key) SetName(name string) {
+	k.name = k.section.doc.normalize(name)
+	k.rawName = name
+	if k.array {
+		k.rawName += "[]"
+	}
+}
+
+// Change the value of the key, quoting it if needed.
+// The rest of the line is kept as is
+func (k *Key) SetValue(value string) {
+	k.value = value
+	k.rawValue = quoteValue(value)
+}
+
+func (k *Key) write(buffer *bytes.Buffer) {
+	doc := k.section.doc
+	doc.writeLines(buffer, k.comments)
+	doc.writeLines(buffer, []string{k.indent + k.rawName + k.sep + k.rawValue + k.suffix})
+}
+
+func trimLines(lines []string) []string {
+	trimmed := make([]string, len(lines))
+	for i, line := range lines {
+		trimmed[i] = strings.TrimRight(line, "\r\n")
+	}
+	return trimmed
+}
+
+// Builds a ini.Document from the lines handed by the parser
+type docBuilder struct {
+	doc          *Document
+	section      *Section
+	pending      []string
+	newLineFound bool
+}
+
+func newDocBuilder(lowCaseIds bool, sepChars []byte, bom bool) *docBuilder {
+	doc := &Document{lowCaseIds: lowCaseIds, sepChar: '=', newLine: "\n", bom: bom}
+	if len(sepChars) > 0 {
+		doc.sepChar = sepChars[0]
+	}
+	section := &Section{doc: doc}
+	doc.sections = []*Section{section}
+	return &docBuilder{doc: doc, section: section}
+}
+
+func (b *docBuilder) addLine(node interface{}, raw string, name [2]int, value [2]int) {
+	if raw == "" {
+		return
+	}
+	if !b.newLineFound {
+		if ending := lineEnding([]byte(raw)); ending != "" {
+			b.doc.newLine = ending
+			b.newLineFound = true
+		}
+	}
+	switch n := node.(type) {
+	case *Section:
+		n.doc = b.doc
+		n.comments = b.pending
+		n.prefix = raw[:name[0]]
+		n.rawName = raw[name[0]:name[1]]
+		n.suffix = raw[name[1]:]
+		b.doc.sections = append(b.doc.sections, n)
+		b.section = n
+	case *Key:
+		n.section = b.section
+		n.comments = b.pending
+		n.indent = raw[:name[0]]
+		n.rawName = raw[name[0]:name[1]]
+		n.sep = raw[name[1]:value[0]]
+		n.rawValue = raw[value[0]:value[1]]
+		n.suffix = raw[value[1]:]
+		b.section.keys = append(b.section.keys, n)
+	default:
+		b.pending = append(b.pending, raw)
+		return
+	}
+	b.pending = nil
+}
+
+func (b *docBuilder) finish() *Document {
+	b.doc.trailing = b.pending
+	b.pending = nil
+	return b.doc
+}
+
+func lineEnding(line []byte) string {
+	switch {
+	case bytes.HasSuffix(line, []byte("\r\n")):
+		return "\r\n"
+	case bytes.HasSuffix(line, []byte("\n")):
+		return "\n"
+	case bytes.HasSuffix(line, []byte("\r")):
+		return "\r"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,239 @@
+package ini
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"io/ioutil"
+	"strings"
+)
+
+func parseDocument(content string) *Document {
+	doc, err := ParseDocument(strings.NewReader(content))
+	Expect(err).To(BeNil())
+	return doc
+}
+
+var _ = Describe("Document", func() {
+	config := "; my config\n\n  [section]   ; first\nfoo =   bar ; very important\n\n; baz\nBaz=qux\n\n[other]\nab = cd\n; the end\n"
+
+	Describe("ParseDocument", func() {
+		It("should keep sections and keys in order", func() {
+			doc := parseDocument(config)
+			sections := doc.Sections()
+			Expect(sections).To(HaveLen(3))
+			Expect(sections[0].Name()).To(Equal(""))
+			Expect(sections[1].Name()).To(Equal("section"))
+			Expect(sections[2].Name()).To(Equal("other"))
+			keys := sections[1].Keys()
+			Expect(keys).To(HaveLen(2))
+			Expect(keys[0].Name()).To(Equal("foo"))
+			Expect(keys[0].Value()).To(Equal("bar"))
+			Expect(keys[1].Name()).To(Equal("baz"))
+			Expect(keys[1].Value()).To(Equal("qux"))
+		})
+
+		It("should keep comments", func() {
+			doc := parseDocument(config)
+			Expect(doc.Section("section").Comments()).To(Equal([]string{"; my config", ""}))
+			Expect(doc.Section("section").Key("baz").Comments()).To(Equal([]string{"", "; baz"}))
+			Expect(doc.Comments()).To(Equal([]string{"; the end"}))
+		})
+
+		It("should look up names with the document case rule", func() {
+			doc := parseDocument(config)
+			Expect(doc.Section("SECTION")).NotTo(BeNil())
+			Expect(doc.Section("section").Key("BAZ")).NotTo(BeNil())
+			Expect(doc.Section("nope")).To(BeNil())
+			Expect(doc.Section("section").Key("nope")).To(BeNil())
+		})
+
+		It("should keep global keys in the unnamed section", func() {
+			d := NewDecoder(strings.NewReader("; global\nname = app\n[section]\nfoo = bar\n"))
+			d.AllowGlobalKeys(true)
+			doc, err := d.DecodeDocument()
+			Expect(err).To(BeNil())
+			Expect(doc.Section("").Key("name").Value()).To(Equal("app"))
+			Expect(doc.Section("").Key("name").Comments()).To(Equal([]string{"; global"}))
+			Expect(doc.Config()).To(Equal(Config{"": {"name": "app"}, "section": {"foo": "bar"}}))
+			doc.Section("").Set("port", "80")
+			Expect(doc.String()).To(Equal("; global\nname = app\nport = 80\n[section]\nfoo = bar\n"))
+		})
+
+		It("should return errors", func() {
+			_, err := ParseDocument(strings.NewReader("[section\n"))
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	Describe("Config", func() {
+		It("should return the same config as Decode", func() {
+			var c Config
+			err := NewDecoder(strings.NewReader(config)).Decode(&c)
+			Expect(err).To(BeNil())
+			Expect(parseDocument(config).Config()).To(Equal(c))
+		})
+	})
+
+	Describe("WriteTo", func() {
+		It("should write back the original content", func() {
+			Expect(parseDocument(config).String()).To(Equal(config))
+		})
+
+		It("should keep CRLF and missing final new line", func() {
+			content := "[section]\r\n  foo = bar\r\n\r\nbaz = qux"
+			Expect(parseDocument(content).String()).To(Equal(content))
+		})
+
+		It("should keep empty documents", func() {
+			Expect(parseDocument("").String()).To(Equal(""))
+			Expect(parseDocument("  \n; foo\n  ").String()).To(Equal("  \n; foo\n  "))
+		})
+
+		It("should write back multi-line values", func() {
+			content := "[section]\nfoo = a \\\n  b ; comment\n\n  abc = c\nbar = d\n"
+			d := NewDecoder(strings.NewReader(content))
+			d.Continuation(BackslashContinuation)
+			doc, err := d.DecodeDocument()
+			Expect(err).To(BeNil())
+			Expect(doc.String()).To(Equal(content))
+			doc.Section("section").Set("foo", "e")
+			Expect(doc.String()).To(Equal("[section]\nfoo = e ; comment\n\n  abc = c\nbar = d\n"))
+		})
+
+		It("should write back triple quoted values and heredocs", func() {
+			content := "[section]\nfoo = \"\"\"\na ; b\n\"\"\" ; comment\nbar = <<<EOT\nc\nEOT\n"
+			doc := parseDocument(content)
+			Expect(doc.String()).To(Equal(content))
+			doc.Section("section").Set("bar", "d\ne")
+			Expect(doc.String()).To(Equal("[section]\nfoo = \"\"\"\na ; b\n\"\"\" ; comment\nbar = \"\"\"\nd\ne\"\"\"\n"))
+			Expect(parseDocument(doc.String()).Config()).To(Equal(doc.Config()))
+		})
+
+		It("should write back complex files", func() {
+			content, err := ioutil.ReadFile("./test_data/php.ini")
+			Expect(err).To(BeNil())
+			d := NewDecoder(strings.NewReader(string(content)))
+			d.IdRegexp("^[a-z][a-z0-9_\\. -]+$")
+			doc, err := d.DecodeDocument()
+			Expect(err).To(BeNil())
+			Expect(doc.String()).To(Equal(string(content)))
+			Expect(doc.Section("PHP").Key("engine").Value()).To(Equal("On"))
+		})
+	})
+
+	Describe("editing", func() {
+		It("should only change the value of set keys", func() {
+			doc := parseDocument(config)
+			doc.Section("section").Set("foo", "baz")
+			Expect(doc.String()).To(Equal(strings.Replace(config, "=   bar ;", "=   baz ;", 1)))
+			Expect(doc.Config()["section"]["foo"]).To(Equal("baz"))
+		})
+
+		It("should quote values when needed", func() {
+			doc := parseDocument("[section]\nfoo = \"a ; b\" ; comment\n")
+			Expect(doc.Section("section").Key("foo").Value()).To(Equal("a ; b"))
+			doc.Section("section").Set("foo", "c ; d")
+			Expect(doc.String()).To(Equal("[section]\nfoo = \"c ; d\" ; comment\n"))
+		})
+
+		It("should append new keys with the section style", func() {
+			doc := parseDocument("[section]\n  foo=bar\n\n[other]\nabc = def")
+			doc.Section("section").Set("new_key", "value")
+			doc.Section("other").Set("ghi", "jkl")
+			Expect(doc.String()).To(Equal("[section]\n  foo=bar\n  new_key=value\n\n[other]\nabc = def\nghi = jkl\n"))
+		})
+
+		It("should use the document line endings", func() {
+			doc := parseDocument("[section]\r\nfoo = bar\r\n")
+			doc.AddSection("other").Set("baz", "qux")
+			Expect(doc.String()).To(Equal("[section]\r\nfoo = bar\r\n[other]\r\nbaz = qux\r\n"))
+		})
+
+		It("should insert keys after other keys", func() {
+			doc := parseDocument("[section]\nfoo = bar\nbaz = qux\n")
+			Expect(doc.Section("section").InsertAfter("foo", "abc", "def")).NotTo(BeNil())
+			Expect(doc.Section("section").InsertAfter("", "first", "1")).NotTo(BeNil())
+			Expect(doc.Section("section").InsertAfter("nope", "abc", "def")).To(BeNil())
+			Expect(doc.String()).To(Equal("[section]\nfirst = 1\nfoo = bar\nabc = def\nbaz = qux\n"))
+		})
+
+		It("should delete keys with their comments", func() {
+			doc := parseDocument(config)
+			Expect(doc.Section("section").Delete("baz")).To(BeTrue())
+			Expect(doc.Section("section").Delete("baz")).To(BeFalse())
+			Expect(doc.String()).To(Equal(strings.Replace(config, "\n; baz\nBaz=qux\n", "", 1)))
+		})
+
+		It("should rename keys", func() {
+			doc := parseDocument(config)
+			Expect(doc.Section("section").RenameKey("foo", "new_foo")).To(BeTrue())
+			Expect(doc.Section("section").RenameKey("nope", "new_foo")).To(BeFalse())
+			Expect(doc.String()).To(Equal(strings.Replace(config, "\nfoo =", "\nnew_foo =", 1)))
+			Expect(doc.Section("section").Key("new_foo").Value()).To(Equal("bar"))
+		})
+
+		It("should move keys", func() {
+			doc := parseDocument("[section]\nfoo = bar\nbaz = qux\nabc = def\n")
+			Expect(doc.Section("section").MoveAfter("foo", "baz")).To(BeTrue())
+			Expect(doc.Section("section").MoveAfter("abc", "")).To(BeTrue())
+			Expect(doc.Section("section").MoveAfter("foo", "nope")).To(BeFalse())
+			Expect(doc.String()).To(Equal("[section]\nabc = def\nbaz = qux\nfoo = bar\n"))
+		})
+
+		It("should add, rename, move and delete sections", func() {
+			doc := parseDocument(config)
+			Expect(doc.AddSection("other")).To(Equal(doc.Section("other")))
+			Expect(doc.RenameSection("section", "first")).To(BeTrue())
+			Expect(doc.MoveSectionAfter("other", "")).To(BeTrue())
+			Expect(doc.String()).To(Equal("\n[other]\nab = cd\n; my config\n\n  [first]   ; first\nfoo =   bar ; very important\n\n; baz\nBaz=qux\n; the end\n"))
+			Expect(doc.DeleteSection("first")).To(BeTrue())
+			Expect(doc.DeleteSection("first")).To(BeFalse())
+			Expect(doc.DeleteSection("")).To(BeFalse())
+			Expect(doc.String()).To(Equal("\n[other]\nab = cd\n; the end\n"))
+		})
+
+		It("should keep array and repeated keys", func() {
+			doc := parseDocument("[php]\nextension[] = curl\nextension[]=gd\nport = 80\nport = 443\n")
+			section := doc.Section("php")
+			Expect(section.Values("extension")).To(Equal([]string{"curl", "gd"}))
+			Expect(section.Values("port")).To(Equal([]string{"80", "443"}))
+			Expect(section.Values("nope")).To(BeNil())
+			section.Add("extension", "mbstring")
+			section.Add("port", "8080")
+			section.Add("new_key", "a")
+			section.RenameKey("extension", "ext")
+			Expect(doc.String()).To(Equal("[php]\next[] = curl\next[]=gd\next[] = mbstring\n" +
+				"port = 80\nport = 443\nport = 8080\nnew_key = a\n"))
+		})
+
+		It("should keep subsection headers", func() {
+			content := "[remote \"origin\"] ; main\nurl = a\n"
+			doc := parseDocument(content)
+			Expect(doc.String()).To(Equal(content))
+			Expect(doc.Section("remote.origin").Key("url").Value()).To(Equal("a"))
+			Expect(doc.RenameSection("remote.origin", "remote.upstream")).To(BeTrue())
+			Expect(doc.String()).To(Equal("[remote.upstream] ; main\nurl = a\n"))
+		})
+
+		It("should keep inherited sections unresolved", func() {
+			content := "[production]\nhost = prod\n[staging : production] ; test\nport = 80\n"
+			d := NewDecoder(strings.NewReader(content))
+			d.SectionInheritance(true)
+			doc, err := d.DecodeDocument()
+			Expect(err).To(BeNil())
+			Expect(doc.String()).To(Equal(content))
+			Expect(doc.Section("staging").Parent()).To(Equal("production"))
+			Expect(doc.Section("production").Parent()).To(Equal(""))
+			Expect(doc.Config()["staging"]).To(Equal(map[string]string{"port": "80"}))
+			doc.RenameSection("staging", "testing")
+			Expect(doc.String()).To(Equal("[production]\nhost = prod\n[testing : production] ; test\nport = 80\n"))
+		})
+
+		It("should start a new line after a last line without line ending", func() {
+			doc := parseDocument("[section]\nfoo = bar")
+			doc.Section("section").Set("baz", "qux")
+			Expect(doc.String()).To(Equal("[section]\nfoo = bar\nbaz = qux\n"))
+		})
+	})
+})
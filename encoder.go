@@ -3,44 +3,137 @@ package ini
 import (
 	"bufio"
 	"fmt"
-	"github.com/mitchellh/mapstructure"
 	"io"
+	"sort"
 )
 
+// Struct to write .ini format to an io.Writer
 type Encoder struct {
-	w       *bufio.Writer
-	sepChar byte
+	w            *bufio.Writer
+	sepChar      byte
+	sectionOrder []string
+	keyOrder     map[string][]string
 }
 
+// Creates a new ini.Encoder writing to an io.Writer
 func NewEncoder(w io.Writer) *Encoder {
-	return &Encoder{w: bufio.NewWriter(w), sepChar: '='}
+	return &Encoder{w: bufio.NewWriter(w), sepChar: '=', keyOrder: make(map[string][]string)}
 }
 
+// Set the separator character between keys and values. Defaults to '='
 func (e *Encoder) SepChar(c byte) {
 	e.sepChar = c
 }
 
-func (e *Encoder) writeSection(section string, conf map[string]string) error {
-	s := fmt.Sprintf("[%s]\n", section)
-	if _, err := e.w.WriteString(s); err != nil {
-		return err
+// Set the order in which sections are written. Sections not listed
+// are written afterwards, in struct field order or sorted by name
+func (e *Encoder) SectionOrder(sections ...string) {
+	e.sectionOrder = sections
+}
+
+// Set the order in which the keys of a section are written. Keys not listed
+// are written afterwards, in struct field order or sorted by name
+func (e *Encoder) KeyOrder(section string, keys ...string) {
+	e.keyOrder[section] = keys
+}
+
+// Use the order of the sections and keys of the given document,
+// to write back a config in the order it was decoded
+func (e *Encoder) OrderFrom(doc *Document) {
+	e.sectionOrder = nil
+	for _, s := range doc.Sections() {
+		e.sectionOrder = append(e.sectionOrder, s.Name())
+		keys := make([]string, 0, len(s.Keys()))
+		for _, k := range s.Keys() {
+			keys = append(keys, k.Name())
+		}
+		e.keyOrder[s.Name()] = keys
 	}
-	for key, val := range conf {
-		entry := fmt.Sprintf("%s %c %s\n", key, e.sepChar, val)
-		if _, err := e.w.WriteString(entry); err != nil {
+}
+
+func (e *Encoder) writeSection(section string, keys []string, conf map[string][]string, lists map[string]bool) error {
+	if section != "" {
+		s := fmt.Sprintf("[%s]\n", section)
+		if _, err := e.w.WriteString(s); err != nil {
 			return err
 		}
 	}
+	for _, key := range e.orderKeys(section, keys, conf) {
+		name := key
+		if lists[key] {
+			name += "[]"
+		}
+		for _, value := range conf[key] {
+			entry := fmt.Sprintf("%s %c %s\n", name, e.sepChar, quoteValue(value))
+			if _, err := e.w.WriteString(entry); err != nil {
+				return err
+			}
+		}
+	}
 	return e.w.Flush()
 }
 
+// Encode the given struct or map to the io.Writer. Nested structs and
+// maps are written as sections, and struct fields can be renamed or
+// skipped when empty with the `ini:"name,omitempty"` tag.
+// Slices are written as a key[] = item line for each item.
+// Other values, and the section named "", are written first as global keys.
+// Sections and keys are written in a stable order.
+// An ini.Document is written back unchanged
 func (e *Encoder) Encode(v interface{}) error {
-	var conf Config
-	if err := mapstructure.Decode(v, &conf); err != nil {
+	if doc, ok := v.(*Document); ok {
+		if _, err := doc.WriteTo(e.w); err != nil {
+			return err
+		}
+		return e.w.Flush()
+	}
+	sections, err := marshal(v)
+	if err != nil {
 		return err
 	}
-	for section, values := range conf {
-		e.writeSection(section, values)
+	names := make([]string, len(sections))
+	byName := make(map[string]*marshaledSection, len(sections))
+	for i, section := range sections {
+		names[i] = section.name
+		byName[section.name] = section
+	}
+	for _, name := range orderNames(names, []string{""}, e.sectionOrder, names) {
+		section := byName[name]
+		if err := e.writeSection(name, section.keys, section.values, section.lists); err != nil {
+			return err
+		}
 	}
 	return nil
 }
+
+func (e *Encoder) orderKeys(section string, keys []string, conf map[string][]string) []string {
+	names := make([]string, 0, len(conf))
+	for key := range conf {
+		names = append(names, key)
+	}
+	return orderNames(names, e.keyOrder[section], keys)
+}
+
+// Returns the names following the given orders first,
+// then the remaining ones sorted
+func orderNames(names []string, orders ...[]string) []string {
+	remaining := make(map[string]bool, len(names))
+	for _, name := range names {
+		remaining[name] = true
+	}
+	ordered := make([]string, 0, len(names))
+	for _, order := range orders {
+		for _, name := range order {
+			if remaining[name] {
+				ordered = append(ordered, name)
+				delete(remaining, name)
+			}
+		}
+	}
+	rest := make([]string, 0, len(remaining))
+	for name := range remaining {
+		rest = append(rest, name)
+	}
+	sort.Strings(rest)
+	return append(ordered, rest...)
+}
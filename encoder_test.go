@@ -5,13 +5,15 @@ import (
 	. "github.com/onsi/gomega"
 
 	"bytes"
+	"strings"
+	"time"
 )
 
 var _ = Describe("Encoder", func() {
 	It("should encode sections", func() {
 		c := new(bytes.Buffer)
 		encoder := NewEncoder(c)
-		err := encoder.writeSection("section", map[string]string{"foo": "bar"})
+		err := encoder.writeSection("section", nil, map[string][]string{"foo": {"bar"}}, nil)
 		Expect(err).To(BeNil())
 		Expect(c.String()).To(Equal("[section]\nfoo = bar\n"))
 	})
@@ -23,4 +25,209 @@ var _ = Describe("Encoder", func() {
 		Expect(err).To(BeNil())
 		Expect(c.String()).To(Equal("[section]\nfoo = bar\n"))
 	})
+
+	It("should sort sections and keys of maps", func() {
+		c := new(bytes.Buffer)
+		encoder := NewEncoder(c)
+		err := encoder.Encode(Config{
+			"b": {"d": "1", "c": "2"},
+			"a": {"f": "3", "e": "4"},
+		})
+		Expect(err).To(BeNil())
+		Expect(c.String()).To(Equal("[a]\ne = 4\nf = 3\n[b]\nc = 2\nd = 1\n"))
+	})
+
+	It("should follow struct field order", func() {
+		c := new(bytes.Buffer)
+		encoder := NewEncoder(c)
+		err := encoder.Encode(struct {
+			Second map[string]string
+			First  map[string]string
+		}{map[string]string{"foo": "1"}, map[string]string{"bar": "2"}})
+		Expect(err).To(BeNil())
+		Expect(c.String()).To(Equal("[Second]\nfoo = 1\n[First]\nbar = 2\n"))
+	})
+
+	It("should write global keys first", func() {
+		c := new(bytes.Buffer)
+		encoder := NewEncoder(c)
+		encoder.SectionOrder("section", "")
+		err := encoder.Encode(struct {
+			Section map[string]string `ini:"section"`
+			Name    string            `ini:"name"`
+		}{map[string]string{"foo": "bar"}, "app"})
+		Expect(err).To(BeNil())
+		Expect(c.String()).To(Equal("name = app\n[section]\nfoo = bar\n"))
+		d := NewDecoder(c)
+		d.AllowGlobalKeys(true)
+		var decoded Config
+		Expect(d.Decode(&decoded)).To(BeNil())
+		Expect(decoded).To(Equal(Config{"": {"name": "app"}, "section": {"foo": "bar"}}))
+	})
+
+	It("should follow the given order", func() {
+		c := new(bytes.Buffer)
+		encoder := NewEncoder(c)
+		encoder.SectionOrder("b")
+		encoder.KeyOrder("a", "f")
+		err := encoder.Encode(Config{
+			"b": {"d": "1", "c": "2"},
+			"a": {"e": "3", "f": "4", "g": "5"},
+		})
+		Expect(err).To(BeNil())
+		Expect(c.String()).To(Equal("[b]\nc = 2\nd = 1\n[a]\nf = 4\ne = 3\ng = 5\n"))
+	})
+
+	It("should follow the order of a document", func() {
+		content := "[bb]\ndd = 1\ncc = 2\n[aa]\nff = 3\nee = 4\n"
+		doc, err := ParseDocument(strings.NewReader(content))
+		Expect(err).To(BeNil())
+		c := new(bytes.Buffer)
+		encoder := NewEncoder(c)
+		encoder.OrderFrom(doc)
+		err = encoder.Encode(doc.Config())
+		Expect(err).To(BeNil())
+		Expect(c.String()).To(Equal(content))
+	})
+
+	It("should write documents unchanged", func() {
+		content := "; comment\n[bb]\n dd=1 ; foo\n"
+		doc, err := ParseDocument(strings.NewReader(content))
+		Expect(err).To(BeNil())
+		c := new(bytes.Buffer)
+		err = NewEncoder(c).Encode(doc)
+		Expect(err).To(BeNil())
+		Expect(c.String()).To(Equal(content))
+	})
+
+	It("should encode structs with tags", func() {
+		type server struct {
+			Host    string        `ini:"host"`
+			Port    int           `ini:"port"`
+			Debug   bool          `ini:"debug"`
+			Timeout time.Duration `ini:"timeout"`
+			Tags    []string      `ini:"tags,omitempty"`
+			Ratio   float64       `ini:"ratio,omitempty"`
+		}
+		c := new(bytes.Buffer)
+		err := NewEncoder(c).Encode(&struct {
+			Server server `ini:"server"`
+			Other  *server
+			Nil    *server
+		}{
+			Server: server{"localhost", 8080, true, 30 * time.Second, []string{"a", "b"}, 0},
+			Other:  &server{Host: "remote"},
+		})
+		Expect(err).To(BeNil())
+		Expect(c.String()).To(Equal("[server]\nhost = localhost\nport = 8080\ndebug = true\ntimeout = 30s\ntags[] = a\ntags[] = b\n" +
+			"[Other]\nhost = remote\nport = 0\ndebug = false\ntimeout = 0s\n"))
+	})
+
+	It("should be decoded back", func() {
+		type section struct {
+			Foo string `ini:"foo"`
+			Bar string `ini:"bar"`
+		}
+		type config struct {
+			Section section `ini:"section"`
+		}
+		c := new(bytes.Buffer)
+		err := NewEncoder(c).Encode(config{section{"1", "2"}})
+		Expect(err).To(BeNil())
+		var decoded Config
+		err = NewDecoder(c).Decode(&decoded)
+		Expect(err).To(BeNil())
+		Expect(decoded).To(Equal(Config{"section": {"foo": "1", "bar": "2"}}))
+	})
+
+	It("should write slices as array keys which can be decoded back", func() {
+		type section struct {
+			Extensions []string `ini:"extension"`
+			Ports      []int    `ini:"port"`
+		}
+		type config struct {
+			Section section `ini:"section"`
+		}
+		c := new(bytes.Buffer)
+		err := NewEncoder(c).Encode(config{section{[]string{"a,b", "c"}, []int{80, 443}}})
+		Expect(err).To(BeNil())
+		Expect(c.String()).To(Equal("[section]\nextension[] = a,b\nextension[] = c\nport[] = 80\nport[] = 443\n"))
+		var decoded config
+		err = NewDecoder(c).Decode(&decoded)
+		Expect(err).To(BeNil())
+		Expect(decoded.Section.Extensions).To(Equal([]string{"a,b", "c"}))
+		Expect(decoded.Section.Ports).To(Equal([]int{80, 443}))
+		c.Reset()
+		err = NewEncoder(c).Encode(config{section{[]string{"a,b"}, []int{80}}})
+		Expect(err).To(BeNil())
+		Expect(c.String()).To(Equal("[section]\nextension[] = a,b\nport[] = 80\n"))
+		decoded = config{}
+		err = NewDecoder(c).Decode(&decoded)
+		Expect(err).To(BeNil())
+		Expect(decoded.Section.Extensions).To(Equal([]string{"a,b"}))
+		Expect(decoded.Section.Ports).To(Equal([]int{80}))
+	})
+
+	It("should write nested sections which can be decoded back", func() {
+		type listener struct {
+			Port int `ini:"port"`
+		}
+		type config struct {
+			Server struct {
+				HTTP listener `ini:"http"`
+			} `ini:"server"`
+			Remote map[string]listener `ini:"remote"`
+		}
+		var c config
+		c.Server.HTTP.Port = 80
+		c.Remote = map[string]listener{"my \"origin\"": {22}}
+		buffer := new(bytes.Buffer)
+		err := NewEncoder(buffer).Encode(c)
+		Expect(err).To(BeNil())
+		Expect(buffer.String()).To(Equal("[server.http]\nport = 80\n[remote \"my \\\"origin\\\"\"]\nport = 22\n"))
+		var decoded config
+		err = NewDecoder(buffer).Decode(&decoded)
+		Expect(err).To(BeNil())
+		Expect(decoded).To(Equal(c))
+	})
+
+	It("should quote values which need it", func() {
+		c := new(bytes.Buffer)
+		value := " C:\\foo ; \"bar\""
+		err := NewEncoder(c).Encode(Config{"section": {"foo": value}})
+		Expect(err).To(BeNil())
+		Expect(c.String()).To(Equal("[section]\nfoo = \" C:\\\\foo ; \\\"bar\\\"\"\n"))
+		var decoded Config
+		err = NewDecoder(c).Decode(&decoded)
+		Expect(err).To(BeNil())
+		Expect(decoded["section"]["foo"]).To(Equal(value))
+	})
+
+	It("should quote values starting a heredoc", func() {
+		values := map[string]string{"aa": "<<<EOT", "bb": "b", "cc": "c", "dd": "EOT", "ee": "a\nb\""}
+		c := new(bytes.Buffer)
+		err := NewEncoder(c).Encode(Config{"sec": values})
+		Expect(err).To(BeNil())
+		Expect(c.String()).To(HavePrefix("[sec]\naa = \"<<<EOT\"\n"))
+		var decoded Config
+		err = NewDecoder(c).Decode(&decoded)
+		Expect(err).To(BeNil())
+		Expect(decoded["sec"]).To(Equal(values))
+	})
+
+	It("should write multi-line values which can be decoded back", func() {
+		values := map[string]string{
+			"foo": "-----BEGIN CERTIFICATE-----\nMIIB ; #\n-----END CERTIFICATE-----\n",
+			"bar": "print(\"\"\"a\"\"\")\nprint(\"b\")",
+		}
+		c := new(bytes.Buffer)
+		err := NewEncoder(c).Encode(Config{"section": values})
+		Expect(err).To(BeNil())
+		Expect(c.String()).To(Equal("[section]\nbar = <<<EOT\nprint(\"\"\"a\"\"\")\nprint(\"b\")\nEOT\n" +
+			"foo = \"\"\"\n-----BEGIN CERTIFICATE-----\nMIIB ; #\n-----END CERTIFICATE-----\n\"\"\"\n"))
+		var decoded Config
+		err = NewDecoder(c).Decode(&decoded)
+		Expect(err).To(BeNil())
+		Expect(decoded["section"]).To(Equal(values))
+	})
 })
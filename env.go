@@ -0,0 +1,111 @@
+package ini
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// Error returned when overriding keys without a prefix, which would let
+// any variable sharing the name of a key, such as HOME, override it
+var errNoEnvPrefix = errors.New("Decode error. Environment overrides require an EnvPrefix.")
+
+// Returns the name of the environment variable overriding a key, made of
+// the prefix, the section and the key joined with underscores and
+// upper-cased. Other characters than letters and digits are replaced by
+// underscores, so that the port key of [server.http] is overridden
+// by APP_SERVER_HTTP_PORT with the APP prefix
+func EnvVarName(prefix string, section string, key string) string {
+	var parts []string
+	for _, part := range []string{prefix, section, key} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.ToUpper(strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, strings.Join(parts, "_")))
+}
+
+// Replaces the parsed values by the environment variables named after
+// their keys. The keys of the struct pointed to by v are looked up too,
+// so that they can be set even when missing from the file
+func (p *parser) overrideFromEnv(opts Options, v interface{}) error {
+	if opts.EnvPrefix == "" {
+		return errNoEnvPrefix
+	}
+	lookupEnv := opts.LookupEnv
+	if lookupEnv == nil {
+		lookupEnv = os.LookupEnv
+	}
+	envName := opts.EnvName
+	if envName == nil {
+		envName = EnvVarName
+	}
+	keys := make(map[string]map[string]bool)
+	for section, values := range p.currentConfig {
+		for key := range values {
+			addEnvKey(keys, section, key)
+		}
+	}
+	if t := reflect.TypeOf(v); t != nil && t.Kind() == reflect.Ptr {
+		p.structEnvKeys(keys, "", indirectType(t.Elem()), make(map[reflect.Type]bool))
+	}
+	for section, names := range keys {
+		for key := range names {
+			name := envName(opts.EnvPrefix, section, key)
+			value, ok := lookupEnv(name)
+			if !ok {
+				continue
+			}
+			if _, ok := p.currentConfig[section]; !ok {
+				p.currentConfig[section] = make(map[string]string)
+			}
+			p.currentConfig[section][key] = value
+			delete(p.currentLists[section], key)
+			addEnvKey(p.overridden, section, key)
+			p.setPosition(section, key, Position{File: "$" + name})
+		}
+	}
+	return nil
+}
+
+// Adds the keys which can be decoded into a struct, nested structs
+// giving dotted sections, and its fields which are not sections
+// giving keys of the given section. Structs nested in themselves,
+// given in visiting, are not walked again
+func (p *parser) structEnvKeys(keys map[string]map[string]bool, section string, t reflect.Type, visiting map[reflect.Type]bool) {
+	if t.Kind() != reflect.Struct || visiting[t] {
+		return
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+	for _, field := range structFields(t) {
+		ft := t.FieldByIndex(field.index).Type
+		name := field.name
+		if p.lowCaseIds {
+			name = foldCase(name)
+		}
+		switch {
+		case isRepeatedSection(ft):
+		case isSectionType(ft):
+			if section != "" {
+				name = section + "." + name
+			}
+			p.structEnvKeys(keys, name, indirectType(ft), visiting)
+		default:
+			addEnvKey(keys, section, name)
+		}
+	}
+}
+
+func addEnvKey(keys map[string]map[string]bool, section string, key string) {
+	if _, ok := keys[section]; !ok {
+		keys[section] = make(map[string]bool)
+	}
+	keys[section][key] = true
+}
@@ -0,0 +1,119 @@
+package ini
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"strings"
+)
+
+var _ = Describe("env", func() {
+	Describe("EnvVarName", func() {
+		It("should join and upper-case the names", func() {
+			Expect(EnvVarName("APP", "server.http", "port")).To(Equal("APP_SERVER_HTTP_PORT"))
+			Expect(EnvVarName("", "my-section", "max_size")).To(Equal("MY_SECTION_MAX_SIZE"))
+			Expect(EnvVarName("app", "", "name")).To(Equal("APP_NAME"))
+		})
+	})
+
+	Describe("overrideFromEnv", func() {
+		type listener struct {
+			Port int `ini:"port"`
+		}
+		type config struct {
+			Name   string
+			Server struct {
+				Host  string
+				Tags  []string
+				HTTP  listener `ini:"http"`
+				Debug bool
+			}
+		}
+		env := fakeEnv(map[string]string{
+			"APP_NAME":             "app",
+			"APP_SERVER_HOST":      "example.com",
+			"APP_SERVER_TAGS":      "c,d",
+			"APP_SERVER_HTTP_PORT": "8080",
+			"APP_OTHER_KEY":        "other",
+		})
+
+		decode := func(content string, v interface{}, configure func(d *Decoder)) error {
+			d := NewDecoder(strings.NewReader(content))
+			d.EnvOverrides(true)
+			d.EnvPrefix("APP")
+			d.LookupEnv(env)
+			if configure != nil {
+				configure(d)
+			}
+			return d.Decode(v)
+		}
+
+		It("should override file values and struct keys", func() {
+			var c config
+			err := decode("[server]\nhost = localhost\ntags[] = a\ntags[] = b\ndebug = on\n", &c, nil)
+			Expect(err).To(BeNil())
+			Expect(c.Name).To(Equal("app"))
+			Expect(c.Server.Host).To(Equal("example.com"))
+			Expect(c.Server.Tags).To(Equal([]string{"c", "d"}))
+			Expect(c.Server.HTTP.Port).To(Equal(8080))
+			Expect(c.Server.Debug).To(BeTrue())
+		})
+
+		It("should only override file keys for maps", func() {
+			var c Config
+			err := decode("[server]\nhost = localhost\n[other]\nkey = value\n", &c, nil)
+			Expect(err).To(BeNil())
+			Expect(c).To(Equal(Config{"server": {"host": "example.com"}, "other": {"key": "other"}}))
+		})
+
+		It("should use the given name function", func() {
+			var c Config
+			err := decode("[server]\nhost = localhost\n", &c, func(d *Decoder) {
+				d.EnvName(func(prefix string, section string, key string) string {
+					return prefix + "_" + strings.ToUpper(key)
+				})
+			})
+			Expect(err).To(BeNil())
+			Expect(c["server"]["host"]).To(Equal("localhost"))
+			err = decode("[server]\nname = localhost\n", &c, func(d *Decoder) {
+				d.EnvName(func(prefix string, section string, key string) string {
+					return prefix + "_" + strings.ToUpper(key)
+				})
+			})
+			Expect(err).To(BeNil())
+			Expect(c["server"]["name"]).To(Equal("app"))
+		})
+
+		It("should not walk structs nested in themselves forever", func() {
+			type self struct {
+				Name  string
+				Child *self
+			}
+			var s self
+			err := decode("", &s, nil)
+			Expect(err).To(BeNil())
+			Expect(s.Name).To(Equal("app"))
+			Expect(s.Child).To(BeNil())
+		})
+
+		It("should require a prefix", func() {
+			var c Config
+			err := decode("home = /x\n", &c, func(d *Decoder) {
+				d.AllowGlobalKeys(true)
+				d.EnvPrefix("")
+				d.LookupEnv(fakeEnv(map[string]string{"HOME": "/root"}))
+			})
+			Expect(err).To(Equal(errNoEnvPrefix))
+		})
+
+		It("should interpolate references to overridden keys", func() {
+			var c Config
+			err := decode("[server]\nhost = localhost\nurl = http://${host}\n[other]\nkey = ${nope}\n", &c, func(d *Decoder) {
+				d.Interpolation(true)
+			})
+			Expect(err).To(BeNil())
+			Expect(c["server"]["url"]).To(Equal("http://example.com"))
+			Expect(c["other"]["key"]).To(Equal("other"))
+		})
+	})
+})
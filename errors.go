@@ -0,0 +1,154 @@
+package ini
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Error ending the syntax errors when another one
+// follows the Options.MaxErrors collected ones
+var errTooManyErrors = errors.New("Too many errors.")
+
+// Error returned when the content does not follow the .ini syntax
+type SyntaxError struct {
+	File     string // "" for an io.Reader
+	Line     int    // starting at 1
+	Column   int    // starting at 1
+	Offset   int    // in bytes of the UTF-8 content, after the byte order mark
+	Token    string // the offending token, "" at the end of file
+	Expected string // the expected token, "" if not known
+	Message  string
+	source   string
+	tabWidth int
+}
+
+func (e *SyntaxError) Error() string {
+	if e.File != "" {
+		return fmt.Sprintf("Parse error at %s:%d:%d. %s", e.File, e.Line, e.Column, e.Message)
+	}
+	return fmt.Sprintf("Parse error at %d:%d. %s", e.Line, e.Column, e.Message)
+}
+
+// Returns the line of the error followed by a line
+// with a caret under the column of the error
+func (e *SyntaxError) Snippet() string {
+	var caret strings.Builder
+	column := 1
+	for _, r := range e.source {
+		if column >= e.Column {
+			break
+		}
+		if r == '\t' {
+			caret.WriteRune('\t')
+			column = nextTabStop(column, e.tabWidth)
+		} else {
+			caret.WriteRune(' ')
+			column++
+		}
+	}
+	caret.WriteRune('^')
+	return e.source + "\n" + caret.String()
+}
+
+// Returns the column following a tab at the given column
+func nextTabStop(column int, tabWidth int) int {
+	if tabWidth < 1 {
+		tabWidth = 1
+	}
+	return column + tabWidth - (column-1)%tabWidth
+}
+
+// Where the parser is in the content
+type location struct {
+	line   int
+	column int
+	offset int
+}
+
+func (p *parser) location() location {
+	return location{p.currentLine, p.currentChar, p.offset}
+}
+
+// Returns an error at the current token
+func (p *parser) syntaxError(expected string, message string) *SyntaxError {
+	return p.syntaxErrorAt(p.location(), expected, message)
+}
+
+// Returns an error at the given location of the current line.
+// The rest of the line is peeked to be shown by SyntaxError.Snippet
+func (p *parser) syntaxErrorAt(at location, expected string, message string) *SyntaxError {
+	err := &SyntaxError{
+		File:     p.file,
+		Line:     at.line,
+		Column:   at.column,
+		Offset:   at.offset,
+		Expected: expected,
+		Message:  message,
+		tabWidth: p.tabWidth,
+	}
+	if p.currentToken != nil && at == p.location() {
+		err.Token = rawValue(p.currentToken)
+	}
+	raw := p.raw.String()
+	err.source = raw[strings.LastIndexAny(raw, "\r\n")+1:]
+	if p.currentToken != nil && p.currentToken.getType() != newLineTokType {
+		err.source += rawValue(p.currentToken) + p.lex.peekLine()
+	}
+	return err
+}
+
+// Returns an error for a token other than the expected one
+func (p *parser) tokenError(expected string) *SyntaxError {
+	tok := p.currentToken
+	if tok == nil {
+		return p.syntaxError(expected, fmt.Sprintf("Expected %s, got end of file.", expected))
+	}
+	value := stringValue(tok)
+
+	dispVal := " "
+	if value != "" {
+		dispVal += "'" + value + "'"
+	}
+
+	msg := fmt.Sprintf("Expected %s, got %s%s.", expected, tok.getType().String(), dispVal)
+	return p.syntaxError(expected, msg)
+}
+
+// Syntax errors collected when recovering from them
+type errorList struct {
+	errs []error
+	max  int
+}
+
+// Collects a syntax error when recovering from errors, and skips the
+// rest of its line, which is kept as is in the document.
+// Returns the error which should stop the parse, if any
+func (p *parser) recoverFrom(err error) error {
+	var syntaxErr *SyntaxError
+	if p.recovered == nil || !errors.As(err, &syntaxErr) {
+		return err
+	}
+	if p.recovered.max > 0 && len(p.recovered.errs) >= p.recovered.max {
+		return errTooManyErrors
+	}
+	p.recovered.errs = append(p.recovered.errs, err)
+	for token := p.currentToken; token != nil && p.currentLine == syntaxErr.Line; token = p.advance() {
+	}
+	p.lineNode = nil
+	p.endLine()
+	return nil
+}
+
+// Returns the syntax errors collected when recovering from errors,
+// followed by errTooManyErrors if it stopped the parse
+func (p *parser) collectedErrors(err error) error {
+	if p.recovered == nil {
+		return nil
+	}
+	errs := p.recovered.errs
+	if err == errTooManyErrors {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,185 @@
+package ini
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"errors"
+	"strings"
+	"testing/fstest"
+)
+
+var _ = Describe("SyntaxError", func() {
+	syntaxError := func(err error) *SyntaxError {
+		var syntaxErr *SyntaxError
+		Expect(errors.As(err, &syntaxErr)).To(BeTrue())
+		return syntaxErr
+	}
+
+	It("should give the position and tokens of the error", func() {
+		var c Config
+		err := NewDecoder(strings.NewReader("[section]\nfoo = bar\n\tkey[x] = a ; comment\n")).Decode(&c)
+		Expect(err).NotTo(BeNil())
+		e := syntaxError(err)
+		Expect(*e).To(Equal(SyntaxError{
+			Line:     3,
+			Column:   6,
+			Offset:   25,
+			Token:    "x",
+			Expected: "]",
+			Message:  "Expected ], got normal char 'x'.",
+			source:   "\tkey[x] = a ; comment",
+			tabWidth: 1,
+		}))
+		Expect(e.Error()).To(Equal("Parse error at 3:6. Expected ], got normal char 'x'."))
+		Expect(e.Snippet()).To(Equal("\tkey[x] = a ; comment\n\t    ^"))
+	})
+
+	It("should report errors at the end of a line on that line", func() {
+		var c Config
+		err := NewDecoder(strings.NewReader("[section\r\nfoo = bar\r\n")).Decode(&c)
+		e := syntaxError(err)
+		Expect(e.Line).To(Equal(1))
+		Expect(e.Column).To(Equal(9))
+		Expect(e.Offset).To(Equal(8))
+		Expect(e.Token).To(Equal("\r\n"))
+		Expect(e.Snippet()).To(Equal("[section\n        ^"))
+	})
+
+	It("should report errors at the end of file", func() {
+		var c Config
+		err := NewDecoder(strings.NewReader("[section]\nfoo = \"\"\"bar\n")).Decode(&c)
+		e := syntaxError(err)
+		Expect(e.Line).To(Equal(3))
+		Expect(e.Column).To(Equal(1))
+		Expect(e.Token).To(Equal(""))
+		Expect(e.Expected).To(Equal(`"""`))
+		Expect(e.Snippet()).To(Equal("\n^"))
+	})
+
+	It("should count columns in characters and offsets in bytes", func() {
+		var c Config
+		err := NewDecoder(strings.NewReader("[section]\r\nnom = \"café\r\n")).Decode(&c)
+		e := syntaxError(err)
+		Expect(e.Line).To(Equal(2))
+		Expect(e.Column).To(Equal(12))
+		Expect(e.Offset).To(Equal(23))
+		Expect(e.Snippet()).To(Equal("nom = \"café\n           ^"))
+	})
+
+	It("should move to the next tab stop on tabs", func() {
+		d := NewDecoder(strings.NewReader("[section]\n\tfoo = a\n\t\tkey[x] = a\n"))
+		d.TabWidth(4)
+		var c Config
+		e := syntaxError(d.Decode(&c))
+		Expect(e.Column).To(Equal(13))
+		Expect(e.Snippet()).To(Equal("\t\tkey[x] = a\n\t\t    ^"))
+		d = NewDecoder(strings.NewReader("[section]\n\tfoo = a\n  \tbar = b\n"))
+		d.TabWidth(4)
+		Expect(d.Decode(&c)).To(BeNil())
+		pos, _ := d.Position("section", "foo")
+		Expect(pos.Column).To(Equal(5))
+		pos, _ = d.Position("section", "bar")
+		Expect(pos.Column).To(Equal(5))
+	})
+
+	It("should keep invalid UTF-8 in documents", func() {
+		content := "[section]\nfoo = \xffbar\n"
+		doc, err := NewDecoder(strings.NewReader(content)).DecodeDocument()
+		Expect(err).To(BeNil())
+		Expect(doc.String()).To(Equal(content))
+		Expect(doc.Section("section").Key("foo").Value()).To(Equal("\xffbar"))
+	})
+
+	It("should name the file of the error", func() {
+		d := NewFileDecoder("main.ini")
+		d.FS(fstest.MapFS{
+			"main.ini":     {Data: []byte("[section]\n!include conf.d/*.ini\n")},
+			"conf.d/a.ini": {Data: []byte("[section]\n= bar\n")},
+		})
+		d.Includes(true)
+		var c Config
+		e := syntaxError(d.Decode(&c))
+		Expect(e.File).To(Equal("conf.d/a.ini"))
+		Expect(e.Error()).To(Equal("Parse error at conf.d/a.ini:2:1. Unexpected separator token."))
+		Expect(e.Snippet()).To(Equal("= bar\n^"))
+	})
+
+	Describe("recovery", func() {
+		content := "[section]\nfoo = a\n= b\nbar[ = c\n[other\nbaz = d\n[last]\nqux = e\n"
+		unwrap := func(err error) []error {
+			joined, ok := err.(interface{ Unwrap() []error })
+			Expect(ok).To(BeTrue())
+			return joined.Unwrap()
+		}
+
+		It("should collect all the errors and decode the other lines", func() {
+			d := NewDecoder(strings.NewReader(content))
+			d.RecoverErrors(true)
+			var c Config
+			err := d.Decode(&c)
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(Equal("Parse error at 3:1. Unexpected separator token.\n" +
+				"Parse error at 4:5. Expected ], got space ' '.\n" +
+				"Parse error at 5:7. Expected ], got newline ."))
+			Expect(syntaxError(err).Line).To(Equal(3))
+			Expect(c).To(Equal(Config{"section": {"foo": "a", "baz": "d"}, "last": {"qux": "e"}}))
+		})
+
+		It("should stop after the maximum number of errors", func() {
+			d := NewDecoder(strings.NewReader(content))
+			d.RecoverErrors(true)
+			d.MaxErrors(2)
+			var c Config
+			err := d.Decode(&c)
+			errs := unwrap(err)
+			Expect(errs).To(HaveLen(3))
+			Expect(errs[2].Error()).To(Equal("Too many errors."))
+			Expect(c).To(Equal(Config{"section": {"foo": "a"}}))
+		})
+
+		It("should go on after exactly the maximum number of errors", func() {
+			d := NewDecoder(strings.NewReader("[aa]\nfoo\nbar\nok = 3\n"))
+			d.RecoverErrors(true)
+			d.MaxErrors(2)
+			var c Config
+			err := d.Decode(&c)
+			Expect(unwrap(err)).To(HaveLen(2))
+			Expect(errors.Is(err, errTooManyErrors)).To(BeFalse())
+			Expect(c).To(Equal(Config{"aa": {"ok": "3"}}))
+		})
+
+		It("should collect the errors of included files and decode errors", func() {
+			d := NewFileDecoder("main.ini")
+			d.FS(fstest.MapFS{
+				"main.ini": {Data: []byte("[section]\nfoo = a\n!include b.ini\n!include nope.ini\nfoo = b\n")},
+				"b.ini":    {Data: []byte("= b\nzed = c\n")},
+			})
+			d.Includes(true)
+			d.RecoverErrors(true)
+			var c struct {
+				Section struct {
+					Foo string
+					Zed int
+				}
+			}
+			err := d.Decode(&c)
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(HavePrefix("Parse error at b.ini:1:1. Unexpected separator token.\n" +
+				"Parse error at main.ini:4:1. Cannot include nope.ini."))
+			var decodeErr *DecodeError
+			Expect(errors.As(err, &decodeErr)).To(BeTrue())
+			Expect(decodeErr.Key).To(Equal("zed"))
+			Expect(c.Section.Foo).To(Equal("b"))
+		})
+
+		It("should keep the lines with errors in documents", func() {
+			d := NewDecoder(strings.NewReader(content))
+			d.RecoverErrors(true)
+			doc, err := d.DecodeDocument()
+			Expect(err).NotTo(BeNil())
+			Expect(unwrap(err)).To(HaveLen(3))
+			Expect(doc.String()).To(Equal(content))
+		})
+	})
+})
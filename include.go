@@ -0,0 +1,123 @@
+package ini
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Name of the directive including other files, written !include path
+const includeDirective = "include"
+
+// Reads files from the OS, with absolute or relative
+// slash separated names, as fs.FS only allows relative ones
+type osFS struct{}
+
+func (osFS) Open(name string) (fs.File, error) {
+	return os.Open(filepath.FromSlash(name))
+}
+
+func (osFS) Glob(pattern string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.FromSlash(pattern))
+	for i, match := range matches {
+		matches[i] = filepath.ToSlash(match)
+	}
+	return matches, err
+}
+
+// Parses an !include path directive
+func (p *parser) parseInclude() error {
+	at := p.location()
+	p.advance()
+	var buffer bytes.Buffer
+	for token := p.currentToken; token != nil; token = p.advance() {
+		if typ := token.getType(); typ == spaceTokType || typ == newLineTokType || typ == commentTokType {
+			break
+		}
+		buffer.WriteString(stringValue(token))
+	}
+	if directive := buffer.String(); directive != includeDirective {
+		return p.syntaxErrorAt(at, "", fmt.Sprintf("Unknown directive !%s.", directive))
+	}
+	p.skipSpaces()
+	pattern, err := p.parseValue()
+	if err != nil {
+		return err
+	}
+	return p.include(pattern, at)
+}
+
+// Parses the files matching a glob pattern, in order, as if their content
+// was written in place of the directive. Relative patterns are resolved
+// from the directory of the including file. Errors are reported
+// at the given location of the directive
+func (p *parser) include(pattern string, at location) error {
+	if p.fsys == nil {
+		return nil
+	}
+	if pattern == "" {
+		return p.syntaxErrorAt(at, "", "Expected a file to include.")
+	}
+	name := pattern
+	if _, ok := p.fsys.(osFS); !ok {
+		name = strings.TrimPrefix(name, "/")
+	}
+	if !path.IsAbs(name) && p.file != "" {
+		name = path.Join(path.Dir(p.file), name)
+	}
+	names := []string{name}
+	if strings.ContainsAny(name, "*?[") {
+		var err error
+		if names, err = fs.Glob(p.fsys, name); err != nil {
+			return p.syntaxErrorAt(at, "", fmt.Sprintf("Cannot include %s. %s.", pattern, err))
+		}
+	}
+	for _, name := range names {
+		if err := p.includeFile(name, at); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *parser) includeFile(name string, at location) error {
+	for i, included := range p.includeStack {
+		if included == name {
+			cycle := strings.Join(append(p.includeStack[i:], name), " -> ")
+			return p.syntaxErrorAt(at, "", fmt.Sprintf("Include cycle %s.", cycle))
+		}
+	}
+	if p.includeDepth >= p.maxIncludeDepth {
+		return p.syntaxErrorAt(at, "", fmt.Sprintf("Cannot include %s, the maximum depth of %d is exceeded.", name, p.maxIncludeDepth))
+	}
+	file, err := p.fsys.Open(name)
+	if err != nil {
+		return p.syntaxErrorAt(at, "", fmt.Sprintf("Cannot include %s. %s.", name, err))
+	}
+	defer file.Close()
+	child := p.fragment(p.fileLexer(file), name)
+	return child.parseConfig()
+}
+
+// Returns a parser for an included file, sharing the parsed values and
+// starting in the current section. Its lines are not part of the document
+func (p *parser) fragment(lex *lexer, name string) *parser {
+	child := *p
+	child.lex = lex
+	child.currentToken = nil
+	child.currentLine = 1
+	child.currentChar = 0
+	child.offset = 0
+	child.raw = bytes.Buffer{}
+	child.lineNode = nil
+	child.doc = newDocBuilder(p.lowCaseIds, lex.sepChars, lex.bom)
+	child.file = name
+	child.includeStack = append(append([]string(nil), p.includeStack...), name)
+	child.includeDepth = p.includeDepth + 1
+	child.advance()
+	return &child
+}
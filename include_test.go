@@ -0,0 +1,108 @@
+package ini
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing/fstest"
+)
+
+var _ = Describe("include", func() {
+	files := fstest.MapFS{
+		"app.ini":            {Data: []byte("[app]\nname = app\nlevel = 1\n!include conf.d/*.ini\n[app]\nlevel = 4\n")},
+		"conf.d/10-a.ini":    {Data: []byte("[app]\nlevel = 2\n[db]\nhost = a\n")},
+		"conf.d/20-b.ini":    {Data: []byte("[db]\nhost = b\n!include ../extra/port.ini\n")},
+		"extra/port.ini":     {Data: []byte("port = 5432\n")},
+		"key.ini":            {Data: []byte("[app]\ninclude = extra/port.ini\n")},
+		"cycle/a.ini":        {Data: []byte("[aa]\n!include b.ini\n")},
+		"cycle/b.ini":        {Data: []byte("[bb]\n!include a.ini\n")},
+		"deep/a.ini":         {Data: []byte("!include b.ini\n")},
+		"deep/b.ini":         {Data: []byte("!include c.ini\n")},
+		"deep/c.ini":         {Data: []byte("[cc]\nfoo = bar\n")},
+		"bad/main.ini":       {Data: []byte("[aa]\n!include fragment.ini\n")},
+		"bad/fragment.ini":   {Data: []byte("[aa]\nfoo = bar\n= baz\n")},
+		"missing/main.ini":   {Data: []byte("[aa]\n!include nope.ini\n")},
+		"directive/main.ini": {Data: []byte("[aa]\n!require b.ini\n")},
+	}
+
+	decode := func(name string, configure func(d *Decoder)) (Config, error) {
+		d := NewFileDecoder(name)
+		d.FS(files)
+		d.Includes(true)
+		if configure != nil {
+			configure(d)
+		}
+		var c Config
+		err := d.Decode(&c)
+		return c, err
+	}
+
+	It("should include files in order relative to the including file", func() {
+		c, err := decode("app.ini", nil)
+		Expect(err).To(BeNil())
+		Expect(c).To(Equal(Config{
+			"app": {"name": "app", "level": "4"},
+			"db":  {"host": "b", "port": "5432"},
+		}))
+	})
+
+	It("should include files given by the include key", func() {
+		c, err := decode("key.ini", func(d *Decoder) {
+			d.Includes(false)
+			d.IncludeKey("include")
+		})
+		Expect(err).To(BeNil())
+		Expect(c).To(Equal(Config{"app": {"port": "5432"}}))
+	})
+
+	It("should fail on include cycles", func() {
+		_, err := decode("cycle/a.ini", nil)
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(HavePrefix("Parse error at cycle/b.ini:2:"))
+		Expect(err.Error()).To(HaveSuffix("Include cycle cycle/a.ini -> cycle/b.ini -> cycle/a.ini."))
+	})
+
+	It("should limit the include depth", func() {
+		_, err := decode("deep/a.ini", func(d *Decoder) {
+			d.AllowGlobalKeys(true)
+		})
+		Expect(err).To(BeNil())
+		_, err = decode("deep/a.ini", func(d *Decoder) {
+			d.MaxIncludeDepth(1)
+		})
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(ContainSubstring("Cannot include deep/c.ini, the maximum depth of 1 is exceeded."))
+	})
+
+	It("should name the file errors occur in", func() {
+		_, err := decode("bad/main.ini", nil)
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(HavePrefix("Parse error at bad/fragment.ini:3:"))
+		_, err = decode("missing/main.ini", nil)
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(HavePrefix("Parse error at missing/main.ini:2:"))
+		Expect(err.Error()).To(ContainSubstring("Cannot include missing/nope.ini."))
+		_, err = decode("directive/main.ini", nil)
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(ContainSubstring("Unknown directive !require."))
+	})
+
+	It("should keep directives as written in documents", func() {
+		d := NewFileDecoder("app.ini")
+		d.FS(files)
+		d.Includes(true)
+		doc, err := d.DecodeDocument()
+		Expect(err).To(BeNil())
+		Expect(doc.Section("db")).To(BeNil())
+		Expect(doc.String()).To(Equal(string(files["app.ini"].Data)))
+	})
+
+	It("should include files from the OS", func() {
+		d := NewFileDecoder("./test_data/include.ini")
+		d.Includes(true)
+		var c Config
+		err := d.Decode(&c)
+		Expect(err).To(BeNil())
+		Expect(c["section"]).To(Equal(map[string]string{"foo": "bar", "included": "yes"}))
+	})
+})
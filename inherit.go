@@ -0,0 +1,77 @@
+package ini
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Adds to each parsed section the keys it inherits from its parent,
+// given as [child : parent], then from the default section,
+// without overriding its own keys
+func (p *parser) resolveInheritance() error {
+	resolved := make(map[string]bool)
+	var resolve func(name string, chain []string) error
+	resolve = func(name string, chain []string) error {
+		if resolved[name] {
+			return nil
+		}
+		for i, n := range chain {
+			if n == name {
+				loop := strings.Join(append(chain[i:], name), " -> ")
+				return &DecodeError{Section: name, Err: fmt.Errorf("Inheritance loop %s.", loop)}
+			}
+		}
+		if parent, ok := p.parents[name]; ok {
+			if _, ok := p.currentConfig[parent]; !ok {
+				return &DecodeError{Section: name, Err: fmt.Errorf("Unknown parent section %s.", parent)}
+			}
+			if err := resolve(parent, append(chain, name)); err != nil {
+				return err
+			}
+			p.inherit(name, parent)
+		}
+		if p.defaultSection != "" && name != "" && name != p.defaultSection {
+			p.inherit(name, p.defaultSection)
+		}
+		resolved[name] = true
+		return nil
+	}
+	for _, name := range sectionNames(p.currentConfig) {
+		if err := resolve(name, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *parser) inherit(name string, parent string) {
+	from, ok := p.currentConfig[parent]
+	if !ok {
+		return
+	}
+	if _, ok := p.currentLists[name]; !ok {
+		p.currentLists[name] = make(map[string][]string)
+	}
+	for key := range from {
+		if _, ok := p.currentConfig[name][key]; !ok {
+			p.setPosition(name, key, p.positions[parent][key])
+		}
+	}
+	source := sectionValues{from, p.currentLists[parent]}
+	inheritValues(sectionValues{p.currentConfig[name], p.currentLists[name]}, source)
+	for _, occurrence := range p.repeatedSections[name] {
+		inheritValues(occurrence, source)
+	}
+}
+
+func inheritValues(section sectionValues, parent sectionValues) {
+	for key, value := range parent.values {
+		if _, ok := section.values[key]; ok {
+			continue
+		}
+		section.values[key] = value
+		if list, ok := parent.lists[key]; ok {
+			section.lists[key] = list
+		}
+	}
+}
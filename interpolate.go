@@ -0,0 +1,224 @@
+package ini
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	envPrefix  = "ENV:"
+	envDefault = ":-"
+)
+
+// Expands the references to other keys found in the parsed values
+type interpolator struct {
+	conf       config
+	lowCaseIds bool
+	references bool
+	env        bool
+	lookupEnv  func(name string) (string, bool)
+	missingEnv MissingEnvPolicy
+	expanded   map[string]map[string]bool
+	loop       []string
+}
+
+// Replaces ${section.key}, ${key} and %(key)s references in all
+// the parsed values by the value of the key they name, if opts.Interpolation
+// is set. ${key} and %(key)s name a key of the same section, or a global key.
+// If opts.EnvExpansion is set, ${ENV:NAME} and ${NAME:-default} are replaced
+// by the value of the environment variable NAME, other text being kept
+// as is. $$ is replaced by $, and %% by % with opts.Interpolation
+func (p *parser) interpolate(opts Options) error {
+	lookupEnv := opts.LookupEnv
+	if lookupEnv == nil {
+		lookupEnv = os.LookupEnv
+	}
+	in := &interpolator{
+		conf:       p.currentConfig,
+		lowCaseIds: p.lowCaseIds,
+		references: opts.Interpolation,
+		env:        opts.EnvExpansion,
+		lookupEnv:  lookupEnv,
+		missingEnv: opts.MissingEnv,
+		expanded:   p.overridden,
+	}
+	for _, section := range sectionNames(p.currentConfig) {
+		for _, key := range keyNames(p.currentConfig[section]) {
+			if _, err := in.value(section, key); err != nil {
+				return err
+			}
+		}
+		if err := in.expandLists(section, p.currentLists[section]); err != nil {
+			return err
+		}
+		for _, occurrence := range p.repeatedSections[section] {
+			for key, value := range occurrence.values {
+				expanded, err := in.expand(section, value)
+				if err != nil {
+					return &DecodeError{section, key, value, err}
+				}
+				occurrence.values[key] = expanded
+			}
+			if err := in.expandLists(section, occurrence.lists); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the expanded value of a key, expanding it first if needed
+func (in *interpolator) value(section string, key string) (string, error) {
+	value := in.conf[section][key]
+	if in.expanded[section][key] {
+		return value, nil
+	}
+	name := key
+	if section != "" {
+		name = section + "." + key
+	}
+	for i, n := range in.loop {
+		if n == name {
+			loop := strings.Join(append(in.loop[i:], name), " -> ")
+			return "", &DecodeError{section, key, value, fmt.Errorf("Interpolation loop %s.", loop)}
+		}
+	}
+	in.loop = append(in.loop, name)
+	expanded, err := in.expand(section, value)
+	in.loop = in.loop[:len(in.loop)-1]
+	if err != nil {
+		var decodeErr *DecodeError
+		if errors.As(err, &decodeErr) {
+			return "", err
+		}
+		return "", &DecodeError{section, key, value, err}
+	}
+	in.conf[section][key] = expanded
+	if _, ok := in.expanded[section]; !ok {
+		in.expanded[section] = make(map[string]bool)
+	}
+	in.expanded[section][key] = true
+	return expanded, nil
+}
+
+// Expands the values of the lists of a section.
+// Lists can be shared with inheriting sections, so they are copied
+func (in *interpolator) expandLists(section string, lists map[string][]string) error {
+	for key, list := range lists {
+		expanded := make([]string, len(list))
+		for i, value := range list {
+			var err error
+			if expanded[i], err = in.expand(section, value); err != nil {
+				return &DecodeError{section, key, value, err}
+			}
+		}
+		lists[key] = expanded
+	}
+	return nil
+}
+
+func (in *interpolator) expand(section string, value string) (string, error) {
+	if !strings.ContainsAny(value, "$%") {
+		return value, nil
+	}
+	var buffer strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case (c == '$' || c == '%' && in.references) && i+1 < len(value) && value[i+1] == c:
+			buffer.WriteByte(c)
+			i++
+		case strings.HasPrefix(value[i:], "${"):
+			end := strings.IndexByte(value[i:], '}')
+			if end < 0 && !in.references {
+				buffer.WriteByte(c)
+				continue
+			}
+			if end < 0 {
+				return "", fmt.Errorf("Unterminated reference %s.", value[i:])
+			}
+			name, ref := value[i+2:i+end], value[i:i+end+1]
+			var expanded string
+			var err error
+			switch {
+			case in.env && isEnvReference(name):
+				expanded, err = in.envValue(name, ref)
+			case in.references:
+				expanded, err = in.reference(section, name, ref)
+			default:
+				expanded = ref
+			}
+			if err != nil {
+				return "", err
+			}
+			buffer.WriteString(expanded)
+			i += end
+		case in.references && strings.HasPrefix(value[i:], "%("):
+			end := strings.Index(value[i:], ")s")
+			if end < 0 {
+				return "", fmt.Errorf("Unterminated reference %s.", value[i:])
+			}
+			name := value[i+2 : i+end]
+			if strings.Contains(name, ".") {
+				return "", fmt.Errorf("Invalid reference %s.", value[i:i+end+2])
+			}
+			expanded, err := in.reference(section, name, value[i:i+end+2])
+			if err != nil {
+				return "", err
+			}
+			buffer.WriteString(expanded)
+			i += end + 1
+		default:
+			buffer.WriteByte(c)
+		}
+	}
+	return buffer.String(), nil
+}
+
+// Returns the value of the key named by a reference, as section.key
+// or as key for a key of the same section or a global key
+func (in *interpolator) reference(section string, name string, ref string) (string, error) {
+	if in.lowCaseIds {
+		name = foldCase(name)
+	}
+	key := name
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		section, key = name[:i], name[i+1:]
+	} else if _, ok := in.conf[section][key]; !ok {
+		section = ""
+	}
+	if _, ok := in.conf[section][key]; !ok {
+		return "", fmt.Errorf("Unknown reference %s.", ref)
+	}
+	return in.value(section, key)
+}
+
+func isEnvReference(name string) bool {
+	return strings.HasPrefix(name, envPrefix) || strings.Contains(name, envDefault)
+}
+
+// Returns the value of the environment variable named by a reference,
+// as ENV:NAME, NAME:-default or ENV:NAME:-default
+func (in *interpolator) envValue(name string, ref string) (string, error) {
+	name = strings.TrimPrefix(name, envPrefix)
+	def, hasDefault := "", false
+	if i := strings.Index(name, envDefault); i >= 0 {
+		name, def, hasDefault = name[:i], name[i+len(envDefault):], true
+	}
+	value, ok := in.lookupEnv(name)
+	if hasDefault && (!ok || value == "") {
+		return def, nil
+	}
+	if ok {
+		return value, nil
+	}
+	switch in.missingEnv {
+	case MissingEnvEmpty:
+		return "", nil
+	case MissingEnvVerbatim:
+		return ref, nil
+	}
+	return "", fmt.Errorf("Unknown environment variable %s.", name)
+}
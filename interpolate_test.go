@@ -0,0 +1,122 @@
+package ini
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"strings"
+)
+
+func interpolateConfig(content string) (*parser, error) {
+	opts := DefaultOptions
+	opts.AllowGlobalKeys = true
+	opts.Interpolation = true
+	return interpolateWithOptions(content, opts)
+}
+
+func interpolateWithOptions(content string, opts Options) (*parser, error) {
+	pars := newParserFromOptions(strings.NewReader(content), opts)
+	Expect(pars.parseConfig()).To(BeNil())
+	return pars, pars.interpolate(opts)
+}
+
+func fakeEnv(env map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+}
+
+var _ = Describe("interpolate", func() {
+	It("should expand references to other keys", func() {
+		pars, err := interpolateConfig("root = /srv\n[paths]\nhome = ${root}/home\nuser = ${home}/me\n" +
+			"[server.http]\nlog = ${paths.user}/http.log\ncache = %(log)s.cache\n")
+		Expect(err).To(BeNil())
+		Expect(pars.currentConfig["paths"]).To(Equal(map[string]string{"home": "/srv/home", "user": "/srv/home/me"}))
+		Expect(pars.currentConfig["server.http"]).To(Equal(map[string]string{
+			"log":   "/srv/home/me/http.log",
+			"cache": "/srv/home/me/http.log.cache",
+		}))
+	})
+
+	It("should expand lists and repeated sections", func() {
+		opts := DefaultOptions
+		opts.Interpolation = true
+		opts.DuplicateSections = DuplicateSectionRepeat
+		pars, err := interpolateWithOptions("[paths]\nroot = /srv\ndir[] = ${root}/a\ndir[] = ${root}/b\n[paths]\nother = ${root}\n", opts)
+		Expect(err).To(BeNil())
+		Expect(pars.currentLists["paths"]).To(Equal(map[string][]string{}))
+		Expect(pars.repeatedSections["paths"][0].lists["dir"]).To(Equal([]string{"/srv/a", "/srv/b"}))
+		Expect(pars.repeatedSections["paths"][1].values["other"]).To(Equal("/srv"))
+	})
+
+	It("should keep escaped and other dollar and percent signs", func() {
+		pars, err := interpolateConfig("[section]\nprice = $$5 or 100%% ${\nratio = 100% $5\n")
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(ContainSubstring("Unterminated reference ${."))
+		pars, err = interpolateConfig("[section]\nprice = $${foo} or 100%%(foo)s\nratio = 100% $5\n")
+		Expect(err).To(BeNil())
+		Expect(pars.currentConfig["section"]["price"]).To(Equal("${foo} or 100%(foo)s"))
+		Expect(pars.currentConfig["section"]["ratio"]).To(Equal("100% $5"))
+	})
+
+	It("should fail on unknown references", func() {
+		_, err := interpolateConfig("[section]\nfoo = ${bar}\n")
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(Equal("Decode error at [section] foo = ${bar}. Unknown reference ${bar}."))
+		_, err = interpolateConfig("[section]\nfoo = %(other.bar)s\n")
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(ContainSubstring("Invalid reference %(other.bar)s."))
+	})
+
+	It("should fail on loops naming them", func() {
+		_, err := interpolateConfig("[aa]\nfoo = ${bb.bar}\n[bb]\nbar = %(baz)s\nbaz = ${aa.foo}\n")
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(Equal("Decode error at [aa] foo = ${bb.bar}. Interpolation loop aa.foo -> bb.bar -> bb.baz -> aa.foo."))
+	})
+
+	Describe("environment variables", func() {
+		env := fakeEnv(map[string]string{"DB_PASSWORD": "secret", "EMPTY": ""})
+
+		It("should expand environment variables with defaults", func() {
+			opts := DefaultOptions
+			opts.EnvExpansion = true
+			opts.LookupEnv = env
+			pars, err := interpolateWithOptions("[db]\npassword = ${ENV:DB_PASSWORD}\nuser = ${DB_USER:-admin}\n"+
+				"empty = ${EMPTY:-none}\nhost = ${ENV:DB_HOST:-localhost}\nkey = ${password}\n", opts)
+			Expect(err).To(BeNil())
+			Expect(pars.currentConfig["db"]).To(Equal(map[string]string{
+				"password": "secret",
+				"user":     "admin",
+				"empty":    "none",
+				"host":     "localhost",
+				"key":      "${password}",
+			}))
+			pars, err = interpolateWithOptions("[db]\nprice = $$5 or 100%% ${ENV:DB_PASSWORD} %(key)s ${\n", opts)
+			Expect(err).To(BeNil())
+			Expect(pars.currentConfig["db"]["price"]).To(Equal("$5 or 100%% secret %(key)s ${"))
+			opts.Interpolation = true
+			pars, err = interpolateWithOptions("[db]\npassword = ${ENV:DB_PASSWORD}\nkey = ${password}\n", opts)
+			Expect(err).To(BeNil())
+			Expect(pars.currentConfig["db"]["key"]).To(Equal("secret"))
+		})
+
+		It("should handle missing variables as asked to", func() {
+			content := "[db]\nuser = ${ENV:DB_USER}\n"
+			opts := DefaultOptions
+			opts.EnvExpansion = true
+			opts.LookupEnv = env
+			_, err := interpolateWithOptions(content, opts)
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(Equal("Decode error at [db] user = ${ENV:DB_USER}. Unknown environment variable DB_USER."))
+			opts.MissingEnv = MissingEnvEmpty
+			pars, err := interpolateWithOptions(content, opts)
+			Expect(err).To(BeNil())
+			Expect(pars.currentConfig["db"]["user"]).To(Equal(""))
+			opts.MissingEnv = MissingEnvVerbatim
+			pars, err = interpolateWithOptions(content, opts)
+			Expect(err).To(BeNil())
+			Expect(pars.currentConfig["db"]["user"]).To(Equal("${ENV:DB_USER}"))
+		})
+	})
+})
@@ -0,0 +1,32 @@
+package ini
+
+import (
+	"io"
+)
+
+// Parses a file whose values override the ones already parsed, by key,
+// or by section with the FileOverrideSections policy. Keys and sections
+// given again are not duplicates, as duplicates are only looked for
+// within a file and the files it includes
+func (p *parser) parseLayer(rd io.Reader, name string) error {
+	p.keyLines = make(map[string]map[string]int)
+	p.sectionLines = make(map[string]int)
+	layer := p.fragment(p.fileLexer(rd), name)
+	layer.currentSection = ""
+	layer.skipSection = false
+	layer.includeStack = []string{name}
+	layer.includeDepth = 0
+	layer.doc = p.doc
+	p.doc.doc.bom = p.doc.doc.bom || layer.lex.bom
+	return layer.parseConfig()
+}
+
+// Drops the keys of a section given in a previous file
+func (p *parser) dropSection(name string) {
+	delete(p.currentConfig, name)
+	delete(p.currentLists, name)
+	delete(p.repeatedSections, name)
+	delete(p.sectionPaths, name)
+	delete(p.parents, name)
+	delete(p.positions, name)
+}
@@ -0,0 +1,97 @@
+package ini
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing/fstest"
+)
+
+var _ = Describe("layers", func() {
+	files := fstest.MapFS{
+		"etc/app.ini":  {Data: []byte("[server]\nhost = etc\nport = 80\ntags[] = a\n[log]\nlevel = info\n")},
+		"home/app.ini": {Data: []byte("[server]\nport = 8080\ntags[] = b\ntags[] = c\n")},
+		"app.ini":      {Data: []byte("[server]\nhost = local\n!include conf.d/*.ini\n")},
+		"conf.d/a.ini": {Data: []byte("[log]\nlevel = debug\n")},
+	}
+
+	newDecoder := func(names ...string) *Decoder {
+		d := NewFilesDecoder(names...)
+		d.FS(files)
+		d.Includes(true)
+		d.DuplicateKeys(DuplicateKeyError)
+		return d
+	}
+
+	It("should override the values of the previous files by key", func() {
+		d := newDecoder("etc/app.ini", "home/app.ini", "app.ini")
+		var c map[string]map[string][]string
+		err := d.Decode(&c)
+		Expect(err).To(BeNil())
+		Expect(c).To(Equal(map[string]map[string][]string{
+			"server": {"host": {"local"}, "port": {"8080"}, "tags": {"b", "c"}},
+			"log":    {"level": {"debug"}},
+		}))
+		Expect(d.Source("server", "host")).To(Equal("app.ini"))
+		Expect(d.Source("Server", "Port")).To(Equal("home/app.ini"))
+		Expect(d.Source("log", "level")).To(Equal("conf.d/a.ini"))
+		Expect(d.Source("log", "nope")).To(Equal(""))
+	})
+
+	It("should override the values of the previous files by section", func() {
+		d := newDecoder("etc/app.ini", "home/app.ini", "app.ini")
+		d.FileOverride(FileOverrideSections)
+		var c Config
+		err := d.Decode(&c)
+		Expect(err).To(BeNil())
+		Expect(c).To(Equal(Config{"server": {"host": "local"}, "log": {"level": "debug"}}))
+		d = newDecoder("etc/app.ini", "home/app.ini")
+		d.FileOverride(FileOverrideSections)
+		err = d.Decode(&c)
+		Expect(err).To(BeNil())
+		Expect(c["server"]).To(Equal(map[string]string{"port": "8080", "tags": "c"}))
+		Expect(d.Source("log", "level")).To(Equal("etc/app.ini"))
+	})
+
+	It("should skip missing files when asked to", func() {
+		d := newDecoder("etc/app.ini", "nope.ini", "app.ini")
+		var c Config
+		err := d.Decode(&c)
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(ContainSubstring("nope.ini"))
+		d = newDecoder("nope.ini", "etc/app.ini", "app.ini")
+		d.SkipMissingFiles(true)
+		err = d.Decode(&c)
+		Expect(err).To(BeNil())
+		Expect(c["server"]).To(Equal(map[string]string{"host": "local", "port": "80", "tags": "a"}))
+	})
+
+	It("should name environment variables as sources", func() {
+		d := newDecoder("etc/app.ini")
+		d.EnvOverrides(true)
+		d.EnvPrefix("APP")
+		d.LookupEnv(fakeEnv(map[string]string{"APP_SERVER_PORT": "90"}))
+		var c Config
+		err := d.Decode(&c)
+		Expect(err).To(BeNil())
+		Expect(d.Source("server", "port")).To(Equal("$APP_SERVER_PORT"))
+	})
+
+	It("should not decode several files into a document", func() {
+		doc, err := newDecoder("etc/app.ini", "home/app.ini").DecodeDocument()
+		Expect(err).To(Equal(errSeveralFiles))
+		Expect(doc).To(BeNil())
+		doc, err = newDecoder("home/app.ini").DecodeDocument()
+		Expect(err).To(BeNil())
+		Expect(doc.String()).To(Equal("[server]\nport = 8080\ntags[] = b\ntags[] = c\n"))
+	})
+
+	It("should decode files from the OS", func() {
+		var c Config
+		err := DecodeFiles(&c, "./test_data/simple.ini", "./test_data/nope.ini")
+		Expect(err).NotTo(BeNil())
+		err = DecodeFiles(&c, "./test_data/simple.ini", "./test_data/simple.ini")
+		Expect(err).To(BeNil())
+		Expect(c["section"]["foo"]).To(Equal("bar"))
+	})
+})
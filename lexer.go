@@ -4,12 +4,14 @@ import (
 	"bufio"
 	"bytes"
 	"io"
+	"unicode/utf8"
 )
 
 type lexer struct {
 	rd           *bufio.Reader
 	sepChars     []byte
 	commentChars []byte
+	bom          bool // a UTF-8 byte order mark was skipped
 }
 
 func newLexer(rd io.Reader) *lexer {
@@ -17,7 +19,7 @@ func newLexer(rd io.Reader) *lexer {
 }
 
 func newLexerWithOptions(rd io.Reader, sepChars []byte, commentChars []byte) *lexer {
-	return &lexer{bufio.NewReader(rd), sepChars, commentChars}
+	return &lexer{rd: bufio.NewReader(rd), sepChars: sepChars, commentChars: commentChars}
 }
 
 func (l *lexer) peekNext() (byte, error) {
@@ -28,26 +30,51 @@ func (l *lexer) peekNext() (byte, error) {
 	return bytes[0], nil
 }
 
+// Returns the rest of the current line without consuming it,
+// up to the size of the buffer
+func (l *lexer) peekLine() string {
+	for n := 64; ; n *= 2 {
+		buf, err := l.rd.Peek(n)
+		if i := bytes.IndexAny(buf, "\r\n"); i >= 0 {
+			return string(buf[:i])
+		}
+		if err != nil {
+			return string(buf)
+		}
+	}
+}
+
 func (l *lexer) nextToken() (token, error) {
-	nextByte, err := l.rd.ReadByte()
+	r, size, err := l.rd.ReadRune()
 	if err != nil {
 		return nil, err
 	}
+	if r >= utf8.RuneSelf {
+		if r == utf8.RuneError && size == 1 {
+			// keep invalid bytes as they are
+			_ = l.rd.UnreadRune()
+			b, _ := l.rd.ReadByte()
+			return &otherToken{string([]byte{b})}, nil
+		}
+		return &otherToken{string(r)}, nil
+	}
+	nextByte := byte(r)
 
 	switch {
 	case nextByte == ' ' || nextByte == '\t':
 		return &spaceToken{string(nextByte)}, nil
 	case bytes.IndexByte(l.sepChars, nextByte) > -1:
-		return &sepToken{}, nil
+		return &sepToken{string(nextByte)}, nil
 	case bytes.IndexByte(l.commentChars, nextByte) > -1:
-		return &commentToken{}, nil
+		return &commentToken{string(nextByte)}, nil
 	case nextByte == '\n' || nextByte == '\r':
 		if nextByte == '\r' {
 			if n, err := l.peekNext(); err == nil && n == '\n' {
 				_, _ = l.rd.ReadByte()
+				return &newLineToken{"\r\n"}, nil
 			}
 		}
-		return &newLineToken{}, nil
+		return &newLineToken{string(nextByte)}, nil
 	case nextByte == '[' || nextByte == ']' || nextByte == '"':
 		return &symbolToken{string(nextByte)}, nil
 	default:
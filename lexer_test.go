@@ -32,6 +32,13 @@ var _ = Describe("lexer", func() {
 			}
 		})
 
+		It("should keep raw new lines", func() {
+			lex := newLexer(strings.NewReader("\n\r\n\r"))
+			for _, e := range []string{"\n", "\r\n", "\r"} {
+				Expect(rawValue(getToken(lex))).To(Equal(e))
+			}
+		})
+
 		It("should return symbols", func() {
 			lex := newLexer(strings.NewReader("[]\""))
 			expected := []string{"[", "]", "\""}
@@ -64,6 +71,14 @@ var _ = Describe("lexer", func() {
 			}
 		})
 
+		It("should return runes and keep invalid bytes", func() {
+			lex := newLexer(strings.NewReader("é日\xff="))
+			for _, e := range []string{"é", "日", "\xff"} {
+				Expect(getToken(lex).(*otherToken).value).To(Equal(e))
+			}
+			Expect(func() { _ = getToken(lex).(*sepToken) }).NotTo(Panic())
+		})
+
 		It("should work with normal string", func() {
 			lex := newLexer(strings.NewReader("foo = bar ; test\r\n"))
 			for _, e := range []string{"f", "o", "o"} {
@@ -0,0 +1,387 @@
+package ini
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Implemented by types which can encode themselves to an ini value
+type Marshaler interface {
+	MarshalINI() (string, error)
+}
+
+var (
+	durationType      = reflect.TypeOf(time.Duration(0))
+	marshalerType     = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// A struct field with the options of its ini tag
+type fieldInfo struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+// Returns the fields of a struct in order, using the name given in the
+// `ini:"name,omitempty"` tag if any. Fields tagged with "-" are skipped
+// and the fields of embedded structs are flattened
+func structFields(t reflect.Type) []fieldInfo {
+	var fields []fieldInfo
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("ini")
+		if field.PkgPath != "" && !field.Anonymous || tag == "-" {
+			continue
+		}
+		name, opts := parseTag(tag)
+		ft := indirectType(field.Type)
+		if field.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			for _, f := range structFields(ft) {
+				f.index = append([]int{i}, f.index...)
+				fields = append(fields, f)
+			}
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, fieldInfo{name, []int{i}, opts["omitempty"]})
+	}
+	return fields
+}
+
+func parseTag(tag string) (string, map[string]bool) {
+	parts := strings.Split(tag, ",")
+	opts := make(map[string]bool)
+	for _, opt := range parts[1:] {
+		opts[strings.TrimSpace(opt)] = true
+	}
+	return strings.TrimSpace(parts[0]), opts
+}
+
+// Returns the field of v at the given index, or an invalid
+// value if it goes through a nil embedded pointer
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 {
+			v = indirectValue(v)
+			if !v.IsValid() {
+				return v
+			}
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// Follows pointers and interfaces. Returns an invalid value if nil
+func indirectValue(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// A section ready to be written, with its keys in order.
+// Keys with several values are written once per value,
+// and the keys of slices with the key[] syntax
+type marshaledSection struct {
+	name   string
+	keys   []string
+	values map[string][]string
+	lists  map[string]bool
+}
+
+func newMarshaledSection(name string) *marshaledSection {
+	return &marshaledSection{name: name, values: make(map[string][]string), lists: make(map[string]bool)}
+}
+
+func (s *marshaledSection) add(key string, list bool, values ...string) {
+	if _, ok := s.values[key]; !ok {
+		s.keys = append(s.keys, key)
+	}
+	s.values[key] = values
+	s.lists[key] = list
+}
+
+// Converts a struct or a map to sections, in struct field order
+// or sorted by name for maps. Values which are not sections are
+// global keys, returned first in the section named ""
+func marshal(v interface{}) ([]*marshaledSection, error) {
+	rv := indirectValue(reflect.ValueOf(v))
+	if !rv.IsValid() {
+		return nil, fmt.Errorf("Encode error. Cannot encode nil value.")
+	}
+	global := newMarshaledSection("")
+	sections := []*marshaledSection{global}
+	switch rv.Kind() {
+	case reflect.Struct:
+		for _, field := range structFields(rv.Type()) {
+			fv := fieldByIndex(rv, field.index)
+			sv := indirectValue(fv)
+			switch {
+			case sv.IsValid() && isSection(sv):
+				subsections, err := marshalSection(field.name, sv)
+				if err != nil {
+					return nil, err
+				}
+				sections = append(sections, subsections...)
+			case !sv.IsValid() && (!fv.IsValid() || fv.Kind() == reflect.Interface || isSectionType(fv.Type())):
+				continue
+			case field.omitEmpty && isEmptyValue(fv):
+				continue
+			default:
+				values, err := formatValues(fv)
+				if err != nil {
+					return nil, fmt.Errorf("Encode error for %s. %s", field.name, err)
+				}
+				global.add(field.name, isList(fv), values...)
+			}
+		}
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("Encode error. Map keys must be strings.")
+		}
+		for _, key := range sortedKeys(rv) {
+			sv := indirectValue(rv.MapIndex(key))
+			if sv.IsValid() && !isSection(sv) {
+				values, err := formatValues(sv)
+				if err != nil {
+					return nil, fmt.Errorf("Encode error for %s. %s", key.String(), err)
+				}
+				global.add(key.String(), isList(sv), values...)
+				continue
+			}
+			subsections, err := marshalSection(key.String(), sv)
+			if err != nil {
+				return nil, err
+			}
+			if key.String() == "" && subsections[0].name == "" {
+				for _, k := range subsections[0].keys {
+					global.add(k, subsections[0].lists[k], subsections[0].values[k]...)
+				}
+				subsections = subsections[1:]
+			}
+			sections = append(sections, subsections...)
+		}
+	default:
+		return nil, fmt.Errorf("Encode error. Cannot encode %s, expected a struct or a map.", rv.Type())
+	}
+	if len(global.keys) == 0 {
+		sections = sections[1:]
+	}
+	return sections, nil
+}
+
+func isSection(v reflect.Value) bool {
+	return (v.Kind() == reflect.Struct || v.Kind() == reflect.Map) && !isMarshaler(v)
+}
+
+// Returns true if values of the given type are encoded as sections
+func isSectionType(t reflect.Type) bool {
+	t = indirectType(t)
+	if t.Kind() != reflect.Struct && t.Kind() != reflect.Map {
+		return false
+	}
+	pt := reflect.PtrTo(t)
+	return !pt.Implements(marshalerType) && !pt.Implements(textMarshalerType)
+}
+
+func isMarshaler(v reflect.Value) bool {
+	t := v.Type()
+	if v.CanAddr() {
+		t = reflect.PtrTo(t)
+	}
+	return t.Implements(marshalerType) || t.Implements(textMarshalerType)
+}
+
+// Converts a struct or a map to a section, followed by its subsections:
+// nested structs or maps are written as [name.field] for struct fields,
+// and as [name "key"] for map entries. A section without keys
+// is left out when it has subsections
+func marshalSection(name string, v reflect.Value) ([]*marshaledSection, error) {
+	section := newMarshaledSection(name)
+	sections := []*marshaledSection{section}
+	if !v.IsValid() {
+		return sections, nil
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		for _, field := range structFields(v.Type()) {
+			fv := fieldByIndex(v, field.index)
+			sv := indirectValue(fv)
+			if sv.IsValid() && isSection(sv) {
+				subsections, err := marshalSection(name+"."+field.name, sv)
+				if err != nil {
+					return nil, err
+				}
+				sections = append(sections, subsections...)
+				continue
+			}
+			if !sv.IsValid() && fv.IsValid() && isSectionType(fv.Type()) {
+				continue
+			}
+			if field.omitEmpty && isEmptyValue(fv) {
+				continue
+			}
+			values, err := formatValues(fv)
+			if err != nil {
+				return nil, fmt.Errorf("Encode error for %s.%s. %s", name, field.name, err)
+			}
+			section.add(field.name, isList(fv), values...)
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("Encode error for %s. Map keys must be strings.", name)
+		}
+		for _, key := range sortedKeys(v) {
+			if sv := indirectValue(v.MapIndex(key)); sv.IsValid() && isSection(sv) {
+				subsections, err := marshalSection(subsectionName(name, key.String()), sv)
+				if err != nil {
+					return nil, err
+				}
+				sections = append(sections, subsections...)
+				continue
+			}
+			values, err := formatValues(v.MapIndex(key))
+			if err != nil {
+				return nil, fmt.Errorf("Encode error for %s.%s. %s", name, key.String(), err)
+			}
+			section.add(key.String(), isList(v.MapIndex(key)), values...)
+		}
+	default:
+		return nil, fmt.Errorf("Encode error for %s. Cannot encode %s as a section.", name, v.Type())
+	}
+	if len(section.keys) == 0 && len(sections) > 1 {
+		sections = sections[1:]
+	}
+	return sections, nil
+}
+
+// Returns the name of a subsection written as [name "subsection"]
+func subsectionName(name string, subsection string) string {
+	return name + ` "` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(subsection) + `"`
+}
+
+func sortedKeys(v reflect.Value) []reflect.Value {
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+	return keys
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// Returns true for the slices and arrays which are written
+// with the key[] syntax, one item per line
+func isList(v reflect.Value) bool {
+	v = indirectValue(v)
+	return v.IsValid() && (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) && !isMarshaler(v)
+}
+
+// Formats each item of a slice or an array, to be written as
+// a key[] line. Other values are formatted as a single value
+func formatValues(v reflect.Value) ([]string, error) {
+	v = indirectValue(v)
+	if !isList(v) {
+		value, err := formatValue(v)
+		return []string{value}, err
+	}
+	values := make([]string, v.Len())
+	for i := range values {
+		value, err := formatValue(v.Index(i))
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// Formats a single value, using its ini.Marshaler or encoding.TextMarshaler
+// implementation if any. Slices and arrays are joined with commas
+func formatValue(v reflect.Value) (string, error) {
+	v = indirectValue(v)
+	if !v.IsValid() {
+		return "", nil
+	}
+	if v.CanAddr() {
+		v = v.Addr()
+	}
+	switch m := v.Interface().(type) {
+	case Marshaler:
+		return m.MarshalINI()
+	case encoding.TextMarshaler:
+		text, err := m.MarshalText()
+		return string(text), err
+	}
+	v = indirectValue(v)
+	switch v.Type() {
+	case durationType:
+		return time.Duration(v.Int()).String(), nil
+	case byteSizeType:
+		return ByteSize(v.Int()).String(), nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 32), nil
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64), nil
+	case reflect.Slice, reflect.Array:
+		values := make([]string, v.Len())
+		for i := range values {
+			value, err := formatValue(v.Index(i))
+			if err != nil {
+				return "", err
+			}
+			values[i] = value
+		}
+		return strings.Join(values, ","), nil
+	default:
+		return "", fmt.Errorf("Unsupported type %s.", v.Type())
+	}
+}
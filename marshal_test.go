@@ -0,0 +1,146 @@
+package ini
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"net"
+	"reflect"
+	"time"
+)
+
+type embeddedFields struct {
+	Embedded string
+}
+
+type taggedFields struct {
+	Name      string `ini:"name"`
+	Skipped   string `ini:"-"`
+	Empty     string `ini:",omitempty"`
+	unexposed string
+	embeddedFields
+}
+
+var _ = Describe("marshal", func() {
+	Describe("structFields", func() {
+		It("should use tags and flatten embedded structs", func() {
+			fields := structFields(reflect.TypeOf(taggedFields{}))
+			Expect(fields).To(Equal([]fieldInfo{
+				{"name", []int{0}, false},
+				{"Empty", []int{2}, true},
+				{"Embedded", []int{4, 0}, false},
+			}))
+		})
+	})
+
+	Describe("formatValue", func() {
+		It("should format Go types", func() {
+			var nilPtr *int
+			n := 42
+			values := map[interface{}]string{
+				"foo":            "foo",
+				true:             "true",
+				-12:              "-12",
+				uint8(12):        "12",
+				1.5:              "1.5",
+				float32(0.1):     "0.1",
+				90 * time.Second: "1m30s",
+				&n:               "42",
+				nilPtr:           "",
+				128 * MegaByte:   "128M",
+				logLevel(1):      "error",
+				[2]int{1, 2}:     "1,2",
+			}
+			for v, expected := range values {
+				value, err := formatValue(reflect.ValueOf(v))
+				Expect(err).To(BeNil())
+				Expect(value).To(Equal(expected))
+			}
+			value, err := formatValue(reflect.ValueOf([]string{"a", "b"}))
+			Expect(err).To(BeNil())
+			Expect(value).To(Equal("a,b"))
+			value, err = formatValue(reflect.ValueOf(net.IPv4(10, 0, 0, 1)))
+			Expect(err).To(BeNil())
+			Expect(value).To(Equal("10.0.0.1"))
+		})
+
+		It("should fail on unsupported types", func() {
+			_, err := formatValue(reflect.ValueOf(map[string]string{}))
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring("Unsupported type"))
+		})
+	})
+
+	Describe("marshal", func() {
+		It("should fail on values which are not structs or maps", func() {
+			_, err := marshal("foo")
+			Expect(err).NotTo(BeNil())
+			_, err = marshal(nil)
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("should return values outside of sections as global keys first", func() {
+			var nilSection *struct{ Foo string }
+			sections, err := marshal(struct {
+				Section struct{ Foo string }
+				Nil     interface{}
+				Empty   *struct{ Foo string }
+				Name    string
+				Port    int `ini:"port,omitempty"`
+			}{Name: "app", Empty: nilSection})
+			Expect(err).To(BeNil())
+			Expect(sections).To(HaveLen(2))
+			Expect(sections[0].name).To(Equal(""))
+			Expect(sections[0].keys).To(Equal([]string{"Name"}))
+			Expect(sections[1].name).To(Equal("Section"))
+			sections, err = marshal(map[string]interface{}{
+				"":        map[string]string{"foo": "bar"},
+				"name":    "app",
+				"section": map[string]string{},
+			})
+			Expect(err).To(BeNil())
+			Expect(sections).To(HaveLen(2))
+			Expect(sections[0].values).To(Equal(map[string][]string{"foo": {"bar"}, "name": {"app"}}))
+		})
+
+		It("should not take marshalers for sections", func() {
+			sections, err := marshal(struct{ Start time.Time }{})
+			Expect(err).To(BeNil())
+			Expect(sections[0].name).To(Equal(""))
+			Expect(sections[0].values["Start"]).To(Equal([]string{"0001-01-01T00:00:00Z"}))
+			sections, err = marshal(struct{ Section struct{ Start time.Time } }{})
+			Expect(err).To(BeNil())
+			Expect(sections[0].values["Start"]).To(Equal([]string{"0001-01-01T00:00:00Z"}))
+		})
+
+		It("should return nested structs and maps as subsections", func() {
+			type http struct {
+				Port int `ini:"port"`
+			}
+			type server struct {
+				Host  string           `ini:"host"`
+				HTTP  http             `ini:"http"`
+				GRPC  *http            `ini:"grpc"`
+				Hosts map[string]*http `ini:"hosts"`
+			}
+			sections, err := marshal(struct {
+				Server server `ini:"server"`
+				Proxy  struct {
+					HTTP http `ini:"http"`
+				} `ini:"proxy"`
+			}{Server: server{"localhost", http{80}, nil, map[string]*http{"a \"b\"": {81}}}})
+			Expect(err).To(BeNil())
+			names := make([]string, len(sections))
+			for i, section := range sections {
+				names[i] = section.name
+			}
+			Expect(names).To(Equal([]string{"server", "server.http", `server.hosts "a \"b\""`, "proxy.http"}))
+		})
+
+		It("should name the failing key", func() {
+			_, err := marshal(map[string]interface{}{"section": map[string]interface{}{"key": make(chan int)}})
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring("section.key"))
+		})
+	})
+})
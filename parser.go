@@ -4,103 +4,192 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"io/fs"
 	"regexp"
 	"strings"
 )
 
 type config map[string]map[string]string
 
-const (
-	idDefaultRegex = "^[a-z][a-z0-9_]+$"
-)
-
-type parseError struct {
-	p       *parser
-	message string
-}
+// All the values of the keys given several times
+// or with the key[] syntax, by section and key
+type listConfig map[string]map[string][]string
 
-func (e parseError) Error() string {
-	return fmt.Sprintf("Parse error at %d:%d. %s",
-		e.p.currentLine, e.p.currentChar, e.message)
+// The values of a section, with all the values
+// of its keys given several times in lists
+type sectionValues struct {
+	values map[string]string
+	lists  map[string][]string
 }
 
-func newTokenError(p *parser, expected string) parseError {
-	tok := p.currentToken
-	value := stringValue(tok)
+const (
+	idDefaultRegex = "^[a-z][a-z0-9_]+$"
+	tripleQuote    = `"""`
+)
 
-	dispVal := " "
-	if value != "" {
-		dispVal += "'" + value + "'"
-	}
+var heredocRegexp = regexp.MustCompile("^<<<([A-Za-z_][A-Za-z0-9_]*)$")
 
-	msg := fmt.Sprintf("Expected %s, got %s%s.", expected, tok.getType().String(), dispVal)
-	return parseError{p, msg}
+type parser struct {
+	lex               *lexer
+	currentToken      token
+	currentLine       int
+	currentChar       int
+	offset            int
+	idRegexp          *regexp.Regexp
+	lowCaseIds        bool
+	allowGlobalKeys   bool
+	continuation      Continuation
+	continuationJoin  string
+	currentSection    string
+	currentConfig     config
+	currentLists      listConfig
+	arrayKey          bool
+	duplicateKeys     DuplicateKeyPolicy
+	duplicateSections DuplicateSectionPolicy
+	keyLines          map[string]map[string]int
+	sectionLines      map[string]int
+	skipSection       bool
+	repeatedSections  map[string][]sectionValues
+	sectionPath       []string
+	sectionPaths      map[string][]string
+	inheritance       bool
+	defaultSection    string
+	sectionParent     string
+	parents           map[string]string
+	overridden        map[string]map[string]bool
+	includes          bool
+	includeKey        string
+	maxIncludeDepth   int
+	fsys              fs.FS
+	file              string
+	includeStack      []string
+	includeDepth      int
+	overrideSections  bool
+	positions         map[string]map[string]Position
+	recovered         *errorList
+	tabWidth          int
+	detectUTF16       bool
+	raw               bytes.Buffer
+	nameSpan          [2]int
+	valueSpan         [2]int
+	lineNode          interface{}
+	doc               *docBuilder
 }
 
-type parser struct {
-	lex            *lexer
-	currentToken   token
-	currentLine    int
-	currentChar    int
-	idRegexp       *regexp.Regexp
-	lowCaseIds     bool
-	currentSection string
-	currentConfig  config
-}
-
-func makeParser(lex *lexer, regex string, lowCaseIds bool) *parser {
-	idRegexp, err := regexp.Compile(regex)
+func makeParser(lex *lexer, opts Options) *parser {
+	idRegexp, err := regexp.Compile(opts.IdRegexp)
 	if err != nil {
 		idRegexp, _ = regexp.Compile(idDefaultRegex)
 	}
+	defaultSection, includeKey := opts.DefaultSection, opts.IncludeKey
+	if opts.LowCaseIds {
+		defaultSection = foldCase(defaultSection)
+		includeKey = foldCase(includeKey)
+	}
+	var fsys fs.FS = osFS{}
+	if opts.FS != nil {
+		fsys = opts.FS
+	}
 	parser := &parser{
-		lex:            lex,
-		currentToken:   nil,
-		currentLine:    1,
-		currentChar:    0,
-		currentSection: "",
-		currentConfig:  make(map[string]map[string]string),
-		idRegexp:       idRegexp,
-		lowCaseIds:     lowCaseIds,
+		lex:               lex,
+		currentToken:      nil,
+		currentLine:       1,
+		currentChar:       0,
+		currentSection:    "",
+		currentConfig:     make(map[string]map[string]string),
+		currentLists:      make(listConfig),
+		duplicateKeys:     opts.DuplicateKeys,
+		duplicateSections: opts.DuplicateSections,
+		keyLines:          make(map[string]map[string]int),
+		sectionLines:      make(map[string]int),
+		repeatedSections:  make(map[string][]sectionValues),
+		sectionPaths:      make(map[string][]string),
+		inheritance:       opts.SectionInheritance,
+		defaultSection:    defaultSection,
+		parents:           make(map[string]string),
+		overridden:        make(map[string]map[string]bool),
+		includes:          opts.Includes,
+		includeKey:        includeKey,
+		maxIncludeDepth:   opts.MaxIncludeDepth,
+		fsys:              fsys,
+		tabWidth:          opts.TabWidth,
+		detectUTF16:       opts.DetectUTF16,
+		overrideSections:  opts.FileOverride == FileOverrideSections,
+		positions:         make(map[string]map[string]Position),
+		idRegexp:          idRegexp,
+		lowCaseIds:        opts.LowCaseIds,
+		allowGlobalKeys:   opts.AllowGlobalKeys,
+		continuation:      opts.Continuation,
+		continuationJoin:  opts.ContinuationJoin,
+		doc:               newDocBuilder(opts.LowCaseIds, lex.sepChars, lex.bom),
+	}
+	if opts.RecoverErrors {
+		parser.recovered = &errorList{max: opts.MaxErrors}
 	}
 	parser.advance()
 	return parser
 }
 
 func newParser(rd io.Reader) *parser {
-	lex := newLexer(rd)
-	return makeParser(lex, idDefaultRegex, true)
+	return newParserFromOptions(rd, DefaultOptions)
 }
 
 func newParserWithOptions(rd io.Reader,
 	idRegexp string, lowCaseIds bool,
 	sepChars []byte, commentChars []byte) *parser {
-	lex := newLexerWithOptions(rd, sepChars, commentChars)
-	return makeParser(lex, idRegexp, lowCaseIds)
+	opts := DefaultOptions
+	opts.IdRegexp = idRegexp
+	opts.LowCaseIds = lowCaseIds
+	opts.SepChars = sepChars
+	opts.CommentChars = commentChars
+	return newParserFromOptions(rd, opts)
+}
+
+func newParserFromOptions(rd io.Reader, opts Options) *parser {
+	text, bom := newTextReader(rd, opts.DetectUTF16)
+	lex := newLexerWithOptions(text, opts.SepChars, opts.CommentChars)
+	lex.bom = bom
+	return makeParser(lex, opts)
+}
+
+// Returns a lexer for an included or layered file, with the same options
+func (p *parser) fileLexer(rd io.Reader) *lexer {
+	text, bom := newTextReader(rd, p.detectUTF16)
+	lex := newLexerWithOptions(text, p.lex.sepChars, p.lex.commentChars)
+	lex.bom = bom
+	return lex
 }
 
 func (p *parser) eat(typ tokenType) (t token, err error) {
 	t = p.currentToken
 	if t != nil && typ != p.currentToken.getType() {
-		err = newTokenError(p, typ.String())
+		err = p.tokenError(typ.String())
 	}
 	p.advance()
 	return
 }
 
+// Moves to the next token. A new line token belongs to the line it ends.
+// Columns count runes, tabs moving to the next multiple of the tab width
 func (p *parser) advance() token {
+	if p.currentToken != nil {
+		raw := rawValue(p.currentToken)
+		p.raw.WriteString(raw)
+		p.offset += len(raw)
+		if p.currentToken.getType() == newLineTokType {
+			p.currentChar = 0
+			p.currentLine += 1
+		} else if raw == "\t" {
+			p.currentChar = nextTabStop(p.currentChar, p.tabWidth) - 1
+		}
+	}
+	p.currentChar += 1
 	tok, err := p.lex.nextToken()
 	if err != nil {
 		// EOF
 		p.currentToken = nil
 		return nil
 	}
-	if tok.getType() == newLineTokType {
-		p.currentChar = 0
-		p.currentLine += 1
-	} else {
-		p.currentChar += 1
-	}
 
 	p.currentToken = tok
 	return p.currentToken
@@ -109,122 +198,491 @@ func (p *parser) advance() token {
 func (p *parser) parseIdentifier() (ident string, err error) {
 	var buffer bytes.Buffer
 
-	shouldStop := func(tokType tokenType) bool {
-		return tokType == commentTokType || tokType == symbolTokType || tokType == sepTokType
+	shouldStop := func(token token) bool {
+		tokType := token.getType()
+		return tokType == commentTokType || tokType == symbolTokType ||
+			tokType == sepTokType || tokType == newLineTokType ||
+			p.inheritance && p.isColon()
 	}
 
-	for token := p.currentToken; token != nil && !shouldStop(token.getType()); token = p.advance() {
-		v := stringValue(token)
-		if p.lowCaseIds {
-			v = strings.ToLower(v)
-		}
-		buffer.WriteString(v)
+	start := p.raw.Len()
+	for token := p.currentToken; token != nil && !shouldStop(token); token = p.advance() {
+		buffer.WriteString(stringValue(token))
 	}
 	ident = strings.TrimRight(buffer.String(), " \t")
+	if p.lowCaseIds {
+		ident = foldCase(ident)
+	}
+	p.nameSpan = p.rawSpan(start)
 
 	if !p.idRegexp.MatchString(ident) {
 		msg := fmt.Sprintf("Bad key name: %s. Should match %s.",
 			ident, p.idRegexp.String())
-		err = parseError{p, msg}
+		err = p.syntaxError("", msg)
 	}
 	return
 }
 
+func (p *parser) isSymbol(symbol string) bool {
+	s, ok := p.currentToken.(*symbolToken)
+	return ok && s.symbol == symbol
+}
+
+// Returns true if the current token is the given quote. Single quotes
+// are normal characters, which only quote the values they start
+func (p *parser) isQuote(quote string) bool {
+	if t, ok := p.currentToken.(*otherToken); ok {
+		return quote == "'" && t.value == quote
+	}
+	return p.isSymbol(quote)
+}
+
 func (p *parser) parseSection() (sectionName string, err error) {
-	if s, err := p.eat(symbolTokType); err != nil || s.(*symbolToken).symbol != "[" {
-		return "", newTokenError(p, "[")
+	if sectionName, err = p.parseSectionName(); err != nil {
+		return
+	}
+	p.advance()
+	return
+}
+
+// Parses a section header up to its closing bracket, which is not consumed.
+// Names can be made of dot separated parts, as in [server.http], and
+// be followed by a quoted subsection name, as in [remote "origin"],
+// which gives the remote.origin section
+func (p *parser) parseSectionName() (sectionName string, err error) {
+	if !p.isSymbol("[") {
+		return "", p.tokenError("[")
 	}
+	p.advance()
 
-	if sectionName, err = p.parseIdentifier(); err != nil {
+	sectionName, err = p.parseIdentifier()
+	if err != nil && !p.validPath(sectionName) {
 		return
 	}
+	err = nil
+	p.sectionPath = strings.Split(sectionName, ".")
+
+	if p.isSymbol("\"") {
+		start := p.nameSpan[0]
+		var subsection string
+		if subsection, err = p.parseQuotedValue(); err != nil {
+			return
+		}
+		p.nameSpan = p.rawSpan(start)
+		p.skipSpaces()
+		sectionName += "." + subsection
+		p.sectionPath = append(p.sectionPath, subsection)
+	}
 
-	if s, err := p.eat(symbolTokType); err != nil || s.(*symbolToken).symbol != "]" {
-		err = newTokenError(p, "]")
+	p.sectionParent = ""
+	if p.inheritance {
+		if err = p.parseParentName(); err != nil {
+			return
+		}
 	}
 
+	if !p.isSymbol("]") {
+		return "", p.tokenError("]")
+	}
 	return
 }
 
+// Parses the name of the parent section following a colon, as in
+// [staging : production]. The span of the section name is kept
+func (p *parser) parseParentName() error {
+	p.skipSpaces()
+	if !p.isColon() {
+		return nil
+	}
+	nameSpan := p.nameSpan
+	p.advance()
+	p.skipSpaces()
+	parent, err := p.parseIdentifier()
+	if err != nil && !p.validPath(parent) {
+		return err
+	}
+	p.sectionParent = parent
+	p.nameSpan = nameSpan
+	return nil
+}
+
+func (p *parser) isColon() bool {
+	return p.currentToken != nil && stringValue(p.currentToken) == ":"
+}
+
+// Returns true if each dot separated part of the name is a valid identifier
+func (p *parser) validPath(name string) bool {
+	parts := strings.Split(name, ".")
+	if len(parts) < 2 {
+		return false
+	}
+	for _, part := range parts {
+		if !p.idRegexp.MatchString(part) {
+			return false
+		}
+	}
+	return true
+}
+
 func (p *parser) parseValue() (value string, err error) {
 	var buffer bytes.Buffer
+	start := p.raw.Len()
+	if p.isQuote("\"") || p.isQuote("'") {
+		if value, err = p.parseQuotedValue(); err != nil {
+			return
+		}
+		p.skipSpaces()
+		if token := p.currentToken; token == nil || token.getType() == newLineTokType || token.getType() == commentTokType {
+			p.valueSpan = p.rawSpan(start)
+			return
+		}
+		// text follows the closing quote, the value is kept verbatim
+		buffer.Write(p.raw.Bytes()[start:])
+	}
 	token := p.currentToken
 	for token != nil && token.getType() != newLineTokType && token.getType() != commentTokType {
-		buffer.WriteString(stringValue(token))
+		buffer.WriteString(rawValue(token))
 		token = p.advance()
 	}
 	value = strings.TrimRight(buffer.String(), " \t")
+	if match := heredocRegexp.FindStringSubmatch(value); match != nil {
+		if value, err = p.parseHeredoc(match[1]); err != nil {
+			return
+		}
+	}
+	p.valueSpan = p.rawSpan(start)
 	return
 }
 
+// Parses the lines following a <<<MARKER value up to the line
+// containing only the marker. The lines are kept verbatim
+func (p *parser) parseHeredoc(marker string) (string, error) {
+	var buffer bytes.Buffer
+	p.skipComment()
+	for first := true; ; first = false {
+		if p.currentToken == nil {
+			return "", p.tokenError(marker)
+		}
+		newLine := rawValue(p.currentToken)
+		p.advance()
+		var line bytes.Buffer
+		for token := p.currentToken; token != nil && token.getType() != newLineTokType; token = p.advance() {
+			line.WriteString(rawValue(token))
+		}
+		if strings.TrimSpace(line.String()) == marker {
+			return buffer.String(), nil
+		}
+		if !first {
+			buffer.WriteString(newLine)
+		}
+		buffer.Write(line.Bytes())
+	}
+}
+
+// Parses a value between double or single quotes. Comment characters
+// and spaces are kept, and escape sequences are decoded in double quotes
+func (p *parser) parseQuotedValue() (string, error) {
+	var buffer bytes.Buffer
+	quote := stringValue(p.currentToken)
+	p.advance()
+	if quote == "\"" && p.isSymbol(quote) {
+		p.advance()
+		if !p.isSymbol(quote) {
+			return "", nil
+		}
+		p.advance()
+		return p.parseTripleQuotedValue()
+	}
+	for !p.isQuote(quote) {
+		token := p.currentToken
+		if token == nil || token.getType() == newLineTokType {
+			return "", p.tokenError(quote)
+		}
+		buffer.WriteString(rawValue(token))
+		p.advance()
+		escaped := quote == "\"" && rawValue(token) == "\\"
+		if next := p.currentToken; escaped && next != nil && next.getType() != newLineTokType {
+			buffer.WriteString(rawValue(next))
+			p.advance()
+		}
+	}
+	p.advance()
+	if quote == "'" {
+		return buffer.String(), nil
+	}
+	value, err := unescape(buffer.String())
+	if err != nil {
+		return "", p.syntaxError("", err.Error())
+	}
+	return value, nil
+}
+
+// Parses a value between triple double quotes, kept verbatim with its
+// new lines and comment characters. A new line right after the opening
+// quotes is ignored
+func (p *parser) parseTripleQuotedValue() (string, error) {
+	var buffer bytes.Buffer
+	if p.currentToken != nil && p.currentToken.getType() == newLineTokType {
+		p.advance()
+	}
+	for !bytes.HasSuffix(buffer.Bytes(), []byte(tripleQuote)) {
+		if p.currentToken == nil {
+			return "", p.tokenError(tripleQuote)
+		}
+		buffer.WriteString(rawValue(p.currentToken))
+		p.advance()
+	}
+	return strings.TrimSuffix(buffer.String(), tripleQuote), nil
+}
+
+// Returns the span of the raw text consumed since start,
+// without its trailing spaces
+func (p *parser) rawSpan(start int) [2]int {
+	text := strings.TrimRight(string(p.raw.Bytes()[start:]), " \t")
+	return [2]int{start, start + len(text)}
+}
+
 func (p *parser) skipSpaces() {
 	for token := p.currentToken; token != nil && token.getType() == spaceTokType; token = p.advance() {
 	}
 }
 
 func (p *parser) parseAssignment() (ident string, value string, err error) {
+	if ident, err = p.parseKey(); err != nil {
+		return
+	}
+	value, err = p.parseAssignedValue()
+	return
+}
+
+// Parses the name of a key, followed by [] for array keys
+func (p *parser) parseKey() (ident string, err error) {
 	ident, err = p.parseIdentifier()
 	if err != nil {
 		return
 	}
+	p.arrayKey = p.isSymbol("[")
+	if p.arrayKey {
+		p.advance()
+		if !p.isSymbol("]") {
+			return "", p.tokenError("]")
+		}
+		p.advance()
+		p.nameSpan = p.rawSpan(p.nameSpan[0])
+	}
+	return
+}
+
+func (p *parser) parseAssignedValue() (value string, err error) {
 	p.skipSpaces()
 	if _, err = p.eat(sepTokType); err != nil {
 		return
 	}
 	p.skipSpaces()
-	value, err = p.parseValue()
-	if err != nil {
+	return p.parseContinuedValue()
+}
+
+// Parses a value followed by its continuation lines when enabled: lines
+// following a value ending with a backslash, or lines which are indented
+// more deeply than the key
+func (p *parser) parseContinuedValue() (value string, err error) {
+	start := p.raw.Len()
+	if value, err = p.parseValue(); err != nil {
 		return
 	}
+	end := p.valueSpan[1]
+	line := p.raw.Bytes()[:start]
+	indent := p.indentWidth(string(line[bytes.LastIndexAny(line, "\r\n")+1:]))
+	for p.currentToken != nil && p.currentToken.getType() == newLineTokType {
+		backslash := p.continuation&BackslashContinuation != 0 &&
+			end > start && p.raw.Bytes()[end-1] == '\\'
+		indented := p.continuation&IndentContinuation != 0 &&
+			p.indentWidth(p.lex.peekLine()) > indent
+		if !backslash && !indented {
+			break
+		}
+		if backslash {
+			value = strings.TrimRight(value[:len(value)-1], " \t")
+		}
+		p.advance()
+		p.skipSpaces()
+		if token := p.currentToken; token == nil ||
+			token.getType() == newLineTokType || token.getType() == commentTokType {
+			break
+		}
+		var next string
+		if next, err = p.parseValue(); err != nil {
+			return
+		}
+		value += p.continuationJoin + next
+		end = p.valueSpan[1]
+	}
+	p.valueSpan = [2]int{start, end}
 	return
 }
 
+// Returns the width in columns of the spaces and tabs starting a line
+func (p *parser) indentWidth(line string) int {
+	column := 1
+	for _, c := range line {
+		switch c {
+		case ' ':
+			column++
+		case '\t':
+			column = nextTabStop(column, p.tabWidth)
+		default:
+			return column - 1
+		}
+	}
+	return column - 1
+}
+
 func (p *parser) skipComment() {
 	for token := p.currentToken; token != nil && token.getType() != newLineTokType; token = p.advance() {
 	}
 }
 
 func (p *parser) changeSection() error {
-	sec, err := p.parseSection()
+	line := p.currentLine
+	sec, err := p.parseSectionName()
 	if err != nil {
 		return err
 	}
 	p.currentSection = sec
-	if _, ok := p.currentConfig[p.currentSection]; !ok {
-		p.currentConfig[p.currentSection] = make(map[string]string)
+	p.lineNode = &Section{name: sec, parent: p.sectionParent}
+	p.skipSection = false
+	if _, ok := p.sectionLines[sec]; !ok && p.overrideSections {
+		p.dropSection(sec)
+	}
+	if p.sectionParent != "" {
+		p.parents[sec] = p.sectionParent
+	}
+	if len(p.sectionPath) > 1 {
+		p.sectionPaths[sec] = p.sectionPath
+	}
+	if first, ok := p.sectionLines[sec]; ok {
+		switch p.duplicateSections {
+		case DuplicateSectionError:
+			return p.syntaxError("", fmt.Sprintf("Duplicate section %s, first defined at line %d.", sec, first))
+		case DuplicateSectionFirst:
+			p.skipSection = true
+		case DuplicateSectionLast:
+			delete(p.currentConfig, sec)
+			delete(p.currentLists, sec)
+			delete(p.keyLines, sec)
+		case DuplicateSectionRepeat:
+			delete(p.currentLists, sec)
+			delete(p.keyLines, sec)
+		}
+	} else {
+		p.sectionLines[sec] = line
+	}
+	p.advance()
+	if _, ok := p.currentConfig[sec]; !ok {
+		p.currentConfig[sec] = make(map[string]string)
+	}
+	if p.duplicateSections == DuplicateSectionRepeat && !p.skipSection {
+		occurrence := sectionValues{make(map[string]string), make(map[string][]string)}
+		p.currentLists[sec] = occurrence.lists
+		p.repeatedSections[sec] = append(p.repeatedSections[sec], occurrence)
 	}
 	return nil
 }
 
 func (p *parser) makeAssignement() error {
-	key, value, err := p.parseAssignment()
+	at := p.location()
+	key, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	first, repeated := p.keyLines[p.currentSection][key]
+	repeated = repeated && !p.skipSection
+	if repeated && !p.arrayKey && p.duplicateKeys == DuplicateKeyError {
+		return p.syntaxError("", fmt.Sprintf("Duplicate key %s, first defined at line %d.", key, first))
+	}
+	value, err := p.parseAssignedValue()
 	if err != nil {
 		return err
 	}
+	p.lineNode = &Key{name: key, value: value, array: p.arrayKey}
+	if p.includeKey != "" && key == p.includeKey {
+		if p.skipSection {
+			return nil
+		}
+		return p.include(value, at)
+	}
+	if p.skipSection || repeated && !p.arrayKey && p.duplicateKeys == DuplicateKeyFirst {
+		return nil
+	}
+	if !repeated {
+		if _, ok := p.keyLines[p.currentSection]; !ok {
+			p.keyLines[p.currentSection] = make(map[string]int)
+		}
+		p.keyLines[p.currentSection][key] = at.line
+	}
+	if _, ok := p.currentConfig[p.currentSection]; !ok {
+		p.currentConfig[p.currentSection] = make(map[string]string)
+	}
+	if p.arrayKey || repeated && p.duplicateKeys == DuplicateKeyList {
+		p.addToList(key, value, repeated)
+	} else {
+		delete(p.currentLists[p.currentSection], key)
+	}
 	p.currentConfig[p.currentSection][key] = value
+	p.setPosition(p.currentSection, key, Position{p.file, at.line, at.column})
+	if occurrences := p.repeatedSections[p.currentSection]; len(occurrences) > 0 {
+		occurrences[len(occurrences)-1].values[key] = value
+	}
 	return nil
 }
 
+// Keeps all the values of a key given with the key[] syntax or repeated,
+// while the config only keeps the last one
+func (p *parser) addToList(key string, value string, repeated bool) {
+	lists, ok := p.currentLists[p.currentSection]
+	if !ok {
+		lists = make(map[string][]string)
+		p.currentLists[p.currentSection] = lists
+	}
+	if !repeated {
+		delete(lists, key)
+	} else if _, ok := lists[key]; !ok {
+		lists[key] = []string{p.currentConfig[p.currentSection][key]}
+	}
+	lists[key] = append(lists[key], value)
+}
+
+func (p *parser) endLine() {
+	p.doc.addLine(p.lineNode, p.raw.String(), p.nameSpan, p.valueSpan)
+	p.raw.Reset()
+	p.lineNode = nil
+}
+
 func (p *parser) parseLine() (err error) {
 	p.skipSpaces()
 	if p.currentToken == nil {
+		p.endLine()
 		return nil
 	}
 	switch t := p.currentToken.(type) {
 	case *symbolToken:
 		if t.symbol != "[" {
-			return parseError{p, fmt.Sprintf("Unexpected token %s.", t.symbol)}
+			return p.syntaxError("", fmt.Sprintf("Unexpected token %s.", t.symbol))
 		}
 		err = p.changeSection()
 	case *otherToken:
-		if p.currentSection == "" {
-			return parseError{p, "Expected section start"}
+		if p.includes && t.value == "!" {
+			err = p.parseInclude()
+			break
+		}
+		if p.currentSection == "" && !p.allowGlobalKeys {
+			return p.syntaxError("[", "Expected section start")
 		}
 		err = p.makeAssignement()
 	case *commentToken:
 		p.skipComment()
 	case *sepToken:
-		return parseError{p, "Unexpected separator token."}
+		return p.syntaxError("", "Unexpected separator token.")
 	default:
 	}
 	if err != nil {
@@ -234,15 +692,24 @@ func (p *parser) parseLine() (err error) {
 	if p.currentToken != nil && p.currentToken.getType() == commentTokType {
 		p.skipComment()
 	}
-	_, err = p.eat(newLineTokType)
+	if _, err = p.eat(newLineTokType); err != nil {
+		return
+	}
+	p.endLine()
 	return
 }
 
-func (p *parser) parseConfig() (err error) {
-	for err = p.parseLine(); p.currentToken != nil; err = p.parseLine() {
-		if err != nil {
-			return
+// Parses all the lines. When recovering from errors, only returns
+// the error stopping the parse, the others being collected
+func (p *parser) parseConfig() error {
+	for {
+		if err := p.parseLine(); err != nil {
+			if err = p.recoverFrom(err); err != nil {
+				return err
+			}
+		}
+		if p.currentToken == nil {
+			return nil
 		}
 	}
-	return
 }
@@ -66,6 +66,47 @@ var _ = Describe("Parser", func() {
 			Expect(err).NotTo(BeNil())
 			Expect(err.Error()).To(ContainSubstring("Bad key name"))
 		})
+
+		It("should fail on unclosed sections", func() {
+			pars := newParser(strings.NewReader("[foo"))
+			_, err := pars.parseSection()
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring("Expected ], got end of file"))
+		})
+
+		It("should accept dotted names and quoted subsections", func() {
+			pars := newParser(strings.NewReader("[Server.HTTP]\n[Remote \"Origin \\\"1\\\"\" ]\n[ab.c]"))
+			Expect(pars.changeSection()).To(BeNil())
+			Expect(pars.currentSection).To(Equal("server.http"))
+			pars.advance()
+			Expect(pars.changeSection()).To(BeNil())
+			Expect(pars.currentSection).To(Equal("remote.Origin \"1\""))
+			Expect(pars.sectionPaths).To(Equal(map[string][]string{
+				"server.http":         {"server", "http"},
+				"remote.Origin \"1\"": {"remote", "Origin \"1\""},
+			}))
+			pars.advance()
+			err := pars.changeSection()
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring("Bad key name: ab.c"))
+		})
+	})
+
+	Describe("parseSection with inheritance", func() {
+		It("should parse parent sections", func() {
+			opts := DefaultOptions
+			opts.SectionInheritance = true
+			pars := newParserFromOptions(strings.NewReader("[Staging : Production]\n[dev:staging]\n[prod]"), opts)
+			Expect(pars.parseConfig()).To(BeNil())
+			Expect(pars.parents).To(Equal(map[string]string{"staging": "production", "dev": "staging"}))
+		})
+
+		It("should not parse parent sections by default", func() {
+			pars := newParser(strings.NewReader("[staging : production]"))
+			_, err := pars.parseSection()
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring("Bad key name"))
+		})
 	})
 
 	Describe("parseValue", func() {
@@ -79,6 +120,253 @@ var _ = Describe("Parser", func() {
 		})
 	})
 
+	Describe("parseValue with quotes", func() {
+		parseValue := func(content string) (string, error) {
+			pars := newParser(strings.NewReader(content))
+			return pars.parseValue()
+		}
+
+		It("should strip quotes and keep spaces and comments", func() {
+			values := map[string]string{
+				`"C:\foo ; bar"  ; comment`: `C:\foo ; bar`,
+				`"  spaced  "`:              "  spaced  ",
+				`'single # quoted'`:         "single # quoted",
+				`""`:                        "",
+				`"a=b"`:                     "a=b",
+			}
+			for content, expected := range values {
+				v, err := parseValue(content + "\n")
+				Expect(err).To(BeNil())
+				Expect(v).To(Equal(expected))
+			}
+		})
+
+		It("should decode escapes in double quotes only", func() {
+			v, err := parseValue(`"a\"b\\c\nd\te\u00e9"`)
+			Expect(err).To(BeNil())
+			Expect(v).To(Equal("a\"b\\c\nd\teé"))
+			v, err = parseValue(`'a\nb'`)
+			Expect(err).To(BeNil())
+			Expect(v).To(Equal(`a\nb`))
+		})
+
+		It("should keep separators in unquoted values", func() {
+			v, err := parseValue("a=b ; c")
+			Expect(err).To(BeNil())
+			Expect(v).To(Equal("a=b"))
+		})
+
+		It("should fail on unterminated quotes", func() {
+			_, err := parseValue(`"foo`)
+			Expect(err).NotTo(BeNil())
+			_, err = parseValue("\"foo\nbar\"")
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring("Expected \", got newline"))
+		})
+
+		It("should keep values with text after quotes verbatim", func() {
+			values := map[string]string{
+				`"foo" bar ; comment`: `"foo" bar`,
+				`'foo'  bar`:          `'foo'  bar`,
+				`don't`:               `don't`,
+			}
+			for content, expected := range values {
+				v, err := parseValue(content + "\n")
+				Expect(err).To(BeNil())
+				Expect(v).To(Equal(expected))
+			}
+		})
+
+		It("should accept single quotes in names", func() {
+			var c Config
+			d := NewDecoder(strings.NewReader("[O'Brien]\ndon't = 1\n"))
+			d.IdRegexp(".*")
+			err := d.Decode(&c)
+			Expect(err).To(BeNil())
+			Expect(c["o'brien"]).To(Equal(map[string]string{"don't": "1"}))
+		})
+	})
+
+	Describe("parseValue with multi-line values", func() {
+		parseValue := func(content string) (string, error) {
+			pars := newParser(strings.NewReader(content))
+			return pars.parseValue()
+		}
+
+		It("should keep triple quoted values verbatim", func() {
+			v, err := parseValue("\"\"\"\nfirst ; line\n  [second] = \"line\"\\n\n\"\"\" ; comment\nfoo = bar")
+			Expect(err).To(BeNil())
+			Expect(v).To(Equal("first ; line\n  [second] = \"line\"\\n\n"))
+			v, err = parseValue("\"\"\"a\r\nb\"\"\"")
+			Expect(err).To(BeNil())
+			Expect(v).To(Equal("a\r\nb"))
+		})
+
+		It("should fail on unterminated triple quotes", func() {
+			_, err := parseValue("\"\"\"\nfoo\n\"\"\n")
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring("Expected \"\"\", got end of file"))
+		})
+
+		It("should keep heredoc values verbatim", func() {
+			v, err := parseValue("<<<EOT ; comment\n#!/bin/sh\n  echo \"a ; b\"\n\n  EOT\nfoo = bar")
+			Expect(err).To(BeNil())
+			Expect(v).To(Equal("#!/bin/sh\n  echo \"a ; b\"\n"))
+			v, err = parseValue("<<<END\nEND")
+			Expect(err).To(BeNil())
+			Expect(v).To(Equal(""))
+		})
+
+		It("should fail on unterminated heredocs", func() {
+			_, err := parseValue("<<<EOT\nfoo\n")
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring("Expected EOT, got end of file"))
+		})
+
+		It("should keep line numbers", func() {
+			pars := newParser(strings.NewReader("[section]\nfoo = \"\"\"\na\nb\"\"\"\nbar = <<<EOT\nc\nEOT\n[bad"))
+			err := pars.parseConfig()
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(HavePrefix("Parse error at 8:"))
+			Expect(pars.currentConfig["section"]).To(Equal(map[string]string{"foo": "a\nb", "bar": "c"}))
+		})
+	})
+
+	Describe("array keys", func() {
+		It("should keep all the values of array and repeated keys", func() {
+			pars := newParser(strings.NewReader("[section]\nfoo[] = a\nfoo [] = b\nbar = c\nbar = d\nbaz = e\n"))
+			Expect(pars.parseConfig()).To(BeNil())
+			Expect(pars.currentConfig["section"]).To(Equal(map[string]string{"foo": "b", "bar": "d", "baz": "e"}))
+			Expect(pars.currentLists).To(Equal(listConfig{"section": {"foo": {"a", "b"}, "bar": {"c", "d"}}}))
+		})
+
+		It("should fail on unclosed brackets", func() {
+			pars := newParser(strings.NewReader("[section]\nfoo[ = a\n"))
+			err := pars.parseConfig()
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring("Expected ]"))
+		})
+	})
+
+	Describe("duplicate policies", func() {
+		content := "[section]\nfoo = a\nbar[] = b\n[other]\nfoo = c\n[section]\nfoo = d\nbar[] = e\n"
+		parseWith := func(keys DuplicateKeyPolicy, sections DuplicateSectionPolicy) (*parser, error) {
+			opts := DefaultOptions
+			opts.DuplicateKeys = keys
+			opts.DuplicateSections = sections
+			pars := newParserFromOptions(strings.NewReader(content), opts)
+			return pars, pars.parseConfig()
+		}
+
+		It("should merge sections and keep lists by default", func() {
+			pars, err := parseWith(DuplicateKeyList, DuplicateSectionMerge)
+			Expect(err).To(BeNil())
+			Expect(pars.currentConfig["section"]).To(Equal(map[string]string{"foo": "d", "bar": "e"}))
+			Expect(pars.currentLists["section"]).To(Equal(map[string][]string{"foo": {"a", "d"}, "bar": {"b", "e"}}))
+		})
+
+		It("should keep the first or the last key", func() {
+			pars, err := parseWith(DuplicateKeyFirst, DuplicateSectionMerge)
+			Expect(err).To(BeNil())
+			Expect(pars.currentConfig["section"]).To(Equal(map[string]string{"foo": "a", "bar": "e"}))
+			Expect(pars.currentLists["section"]).To(Equal(map[string][]string{"bar": {"b", "e"}}))
+			pars, err = parseWith(DuplicateKeyLast, DuplicateSectionMerge)
+			Expect(err).To(BeNil())
+			Expect(pars.currentConfig["section"]).To(Equal(map[string]string{"foo": "d", "bar": "e"}))
+			Expect(pars.currentLists["section"]).To(Equal(map[string][]string{"bar": {"b", "e"}}))
+		})
+
+		It("should fail on duplicate keys with both lines", func() {
+			_, err := parseWith(DuplicateKeyError, DuplicateSectionMerge)
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(Equal("Parse error at 7:5. Duplicate key foo, first defined at line 2."))
+		})
+
+		It("should keep the first or the last section", func() {
+			pars, err := parseWith(DuplicateKeyError, DuplicateSectionFirst)
+			Expect(err).To(BeNil())
+			Expect(pars.currentConfig["section"]).To(Equal(map[string]string{"foo": "a", "bar": "b"}))
+			pars, err = parseWith(DuplicateKeyError, DuplicateSectionLast)
+			Expect(err).To(BeNil())
+			Expect(pars.currentConfig["section"]).To(Equal(map[string]string{"foo": "d", "bar": "e"}))
+			Expect(pars.currentLists["section"]).To(Equal(map[string][]string{"bar": {"e"}}))
+		})
+
+		It("should fail on duplicate sections with both lines", func() {
+			_, err := parseWith(DuplicateKeyList, DuplicateSectionError)
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(Equal("Parse error at 6:9. Duplicate section section, first defined at line 1."))
+		})
+
+		It("should keep repeated sections separately", func() {
+			pars, err := parseWith(DuplicateKeyError, DuplicateSectionRepeat)
+			Expect(err).To(BeNil())
+			Expect(pars.currentConfig["section"]).To(Equal(map[string]string{"foo": "d", "bar": "e"}))
+			Expect(pars.repeatedSections["section"]).To(Equal([]sectionValues{
+				{map[string]string{"foo": "a", "bar": "b"}, map[string][]string{"bar": {"b"}}},
+				{map[string]string{"foo": "d", "bar": "e"}, map[string][]string{"bar": {"e"}}},
+			}))
+			Expect(pars.repeatedSections["other"]).To(HaveLen(1))
+		})
+	})
+
+	Describe("parseAssignment with continuation", func() {
+		parseWith := func(content string, continuation Continuation, join string) (string, error) {
+			opts := DefaultOptions
+			opts.Continuation = continuation
+			opts.ContinuationJoin = join
+			pars := newParserFromOptions(strings.NewReader(content), opts)
+			_, value, err := pars.parseAssignment()
+			return value, err
+		}
+
+		It("should not continue values by default", func() {
+			v, err := parseWith("foo = a \\\n  b\n", NoContinuation, "\n")
+			Expect(err).To(BeNil())
+			Expect(v).To(Equal("a \\"))
+		})
+
+		It("should join lines ending with a backslash", func() {
+			v, err := parseWith("foo = a,\\\n  b, \\\nc\nbar = d", BackslashContinuation, "")
+			Expect(err).To(BeNil())
+			Expect(v).To(Equal("a,b,c"))
+			v, err = parseWith("foo = a \\\n  b\n", BackslashContinuation, " ")
+			Expect(err).To(BeNil())
+			Expect(v).To(Equal("a b"))
+		})
+
+		It("should not continue quoted values ending with a backslash", func() {
+			v, err := parseWith("foo = \"a\\\\\"\nbar = b", BackslashContinuation, "")
+			Expect(err).To(BeNil())
+			Expect(v).To(Equal("a\\"))
+		})
+
+		It("should join indented lines", func() {
+			v, err := parseWith("foo = select *\n  from t\n\twhere x ; comment\nbar = b", IndentContinuation, "\n")
+			Expect(err).To(BeNil())
+			Expect(v).To(Equal("select *\nfrom t\nwhere x"))
+			v, err = parseWith("foo =\n  a\n  b\n", IndentContinuation, "\n")
+			Expect(err).To(BeNil())
+			Expect(v).To(Equal("\na\nb"))
+		})
+
+		It("should only join lines indented more deeply than the key", func() {
+			var c Config
+			d := NewDecoder(strings.NewReader("[sec]\n  aa = x\n  bb = 2\n\tcc = y\n\t  z\ndd = 3\n"))
+			d.Continuation(IndentContinuation)
+			d.TabWidth(2)
+			err := d.Decode(&c)
+			Expect(err).To(BeNil())
+			Expect(c["sec"]).To(Equal(map[string]string{"aa": "x", "bb": "2", "cc": "y\nz", "dd": "3"}))
+		})
+
+		It("should stop at blank lines", func() {
+			v, err := parseWith("foo = a\n   \n  b\n", IndentContinuation, "\n")
+			Expect(err).To(BeNil())
+			Expect(v).To(Equal("a"))
+		})
+	})
+
 	Describe("skipSpaces", func() {
 		It("should skip all spaces", func() {
 			pars := newParser(strings.NewReader("    a"))
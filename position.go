@@ -0,0 +1,31 @@
+package ini
+
+import "fmt"
+
+// Where the value of a key was read from. File is the name of the file,
+// "" for an io.Reader, or the overriding environment variable written
+// $NAME, in which case Line and Column are 0. Line and Column start at 1
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+// Returns the position as file:line:column, or line:column without a file
+func (p Position) String() string {
+	switch {
+	case p.Line == 0:
+		return p.File
+	case p.File == "":
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
+
+// Records where a key was last assigned
+func (p *parser) setPosition(section string, key string, pos Position) {
+	if _, ok := p.positions[section]; !ok {
+		p.positions[section] = make(map[string]Position)
+	}
+	p.positions[section][key] = pos
+}
@@ -0,0 +1,50 @@
+package ini
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"strings"
+	"testing/fstest"
+)
+
+var _ = Describe("Position", func() {
+	It("should be printed with its file if any", func() {
+		Expect(Position{"conf.d/mem.ini", 3, 1}.String()).To(Equal("conf.d/mem.ini:3:1"))
+		Expect(Position{"", 3, 5}.String()).To(Equal("3:5"))
+		Expect(Position{File: "$APP_PORT"}.String()).To(Equal("$APP_PORT"))
+	})
+
+	It("should give the line and column of keys", func() {
+		d := NewDecoder(strings.NewReader("[section]\nfoo = bar\n  baz = qux\n"))
+		var c Config
+		Expect(d.Decode(&c)).To(BeNil())
+		pos, ok := d.Position("section", "foo")
+		Expect(ok).To(BeTrue())
+		Expect(pos).To(Equal(Position{"", 2, 1}))
+		pos, _ = d.Position("SECTION", "baz")
+		Expect(pos).To(Equal(Position{"", 3, 3}))
+		_, ok = d.Position("section", "nope")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("should follow includes, inheritance and layered files", func() {
+		files := fstest.MapFS{
+			"etc/php.ini":        {Data: []byte("[php]\nmemory_limit = 128M\n!include conf.d/*.ini\n[cli : php]\n")},
+			"etc/conf.d/mem.ini": {Data: []byte("; memory\n[php]\nmemory_limit = 512M\n")},
+			"php.ini":            {Data: []byte("[cli]\n\n    engine = on\n")},
+		}
+		d := NewFilesDecoder("etc/php.ini", "php.ini")
+		d.FS(files)
+		d.Includes(true)
+		d.SectionInheritance(true)
+		var c Config
+		Expect(d.Decode(&c)).To(BeNil())
+		pos, _ := d.Position("php", "memory_limit")
+		Expect(pos.String()).To(Equal("etc/conf.d/mem.ini:3:1"))
+		pos, _ = d.Position("cli", "memory_limit")
+		Expect(pos.String()).To(Equal("etc/conf.d/mem.ini:3:1"))
+		pos, _ = d.Position("cli", "engine")
+		Expect(pos.String()).To(Equal("php.ini:3:5"))
+	})
+})
@@ -0,0 +1,120 @@
+package ini
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Decodes the escape sequences of a double quoted value: \", \\, \n,
+// \r, \t and \uXXXX. Other sequences are kept as is, so that a value
+// such as "C:\foo" is left unchanged
+func unescape(value string) (string, error) {
+	if strings.IndexByte(value, '\\') < 0 {
+		return value, nil
+	}
+	var buffer bytes.Buffer
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c != '\\' || i+1 == len(value) {
+			buffer.WriteByte(c)
+			continue
+		}
+		i++
+		switch value[i] {
+		case '"', '\\':
+			buffer.WriteByte(value[i])
+		case 'n':
+			buffer.WriteByte('\n')
+		case 'r':
+			buffer.WriteByte('\r')
+		case 't':
+			buffer.WriteByte('\t')
+		case 'u':
+			if i+5 > len(value) {
+				return "", fmt.Errorf("Invalid escape sequence \\%s.", value[i:])
+			}
+			r, err := strconv.ParseUint(value[i+1:i+5], 16, 32)
+			if err != nil {
+				return "", fmt.Errorf("Invalid escape sequence \\%s.", value[i:i+5])
+			}
+			buffer.WriteRune(rune(r))
+			i += 4
+		default:
+			buffer.WriteByte(c)
+			buffer.WriteByte(value[i])
+		}
+	}
+	return buffer.String(), nil
+}
+
+// Returns the value as it should be written, quoting
+// and escaping it if it would not be read back as is
+func quoteValue(value string) string {
+	if !needsQuotes(value) {
+		return value
+	}
+	if strings.Contains(value, "\n") {
+		return quoteMultiLine(value)
+	}
+	var buffer bytes.Buffer
+	buffer.WriteByte('"')
+	for _, r := range value {
+		switch r {
+		case '"', '\\':
+			buffer.WriteByte('\\')
+			buffer.WriteRune(r)
+		case '\n':
+			buffer.WriteString(`\n`)
+		case '\r':
+			buffer.WriteString(`\r`)
+		case '\t':
+			buffer.WriteString(`\t`)
+		default:
+			if unicode.IsControl(r) {
+				fmt.Fprintf(&buffer, `\u%04x`, r)
+			} else {
+				buffer.WriteRune(r)
+			}
+		}
+	}
+	buffer.WriteByte('"')
+	return buffer.String()
+}
+
+// Returns a value containing new lines between triple quotes, or as
+// a heredoc when triple quotes would not read it back as is
+func quoteMultiLine(value string) string {
+	if !strings.Contains(value, tripleQuote) && !strings.HasSuffix(value, "\"") {
+		return tripleQuote + "\n" + value + tripleQuote
+	}
+	marker := "EOT"
+	for i := 1; hasLine(value, marker); i++ {
+		marker = fmt.Sprintf("EOT%d", i)
+	}
+	return "<<<" + marker + "\n" + value + "\n" + marker
+}
+
+func hasLine(value string, line string) bool {
+	for _, l := range strings.Split(value, "\n") {
+		if strings.TrimSpace(l) == line {
+			return true
+		}
+	}
+	return false
+}
+
+func needsQuotes(value string) bool {
+	if value == "" {
+		return false
+	}
+	if value != strings.TrimSpace(value) || strings.ContainsAny(value, ";#\n\r") {
+		return true
+	}
+	if value[0] == '"' || value[0] == '\'' || heredocRegexp.MatchString(strings.TrimSpace(value)) {
+		return true
+	}
+	return strings.IndexFunc(value, unicode.IsControl) >= 0
+}
@@ -0,0 +1,66 @@
+package ini
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("quote", func() {
+	Describe("unescape", func() {
+		It("should decode escape sequences", func() {
+			value, err := unescape(`a\"b\\c\nd\te\rf\u00e9`)
+			Expect(err).To(BeNil())
+			Expect(value).To(Equal("a\"b\\c\nd\te\rfé"))
+		})
+
+		It("should keep unknown sequences", func() {
+			value, err := unescape(`C:\foo\bar\`)
+			Expect(err).To(BeNil())
+			Expect(value).To(Equal(`C:\foo\bar\`))
+		})
+
+		It("should fail on invalid unicode sequences", func() {
+			_, err := unescape(`\u12`)
+			Expect(err).NotTo(BeNil())
+			_, err = unescape(`\u12zz`)
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	Describe("quoteValue", func() {
+		It("should not quote simple values", func() {
+			for _, value := range []string{"", "foo", "foo bar", `C:\foo`, `a "b"`, "it's"} {
+				Expect(quoteValue(value)).To(Equal(value))
+			}
+		})
+
+		It("should quote values which would not be read back", func() {
+			values := map[string]string{
+				" foo":     `" foo"`,
+				"foo\t":    `"foo\t"`,
+				"a ; b":    `"a ; b"`,
+				"a # b":    `"a # b"`,
+				`"a"`:      `"\"a\""`,
+				"'a'":      `"'a'"`,
+				`C:\a ; b`: `"C:\\a ; b"`,
+				"a\x00":    `"a\u0000"`,
+			}
+			for value, expected := range values {
+				Expect(quoteValue(value)).To(Equal(expected))
+				unescaped, err := unescape(expected[1 : len(expected)-1])
+				Expect(err).To(BeNil())
+				Expect(unescaped).To(Equal(value))
+			}
+		})
+
+		It("should write values with new lines between triple quotes", func() {
+			Expect(quoteValue("a\nb")).To(Equal("\"\"\"\na\nb\"\"\""))
+			Expect(quoteValue("a ; b\n")).To(Equal("\"\"\"\na ; b\n\"\"\""))
+		})
+
+		It("should write values with triple quotes as heredocs", func() {
+			Expect(quoteValue("a\n\"\"\"")).To(Equal("<<<EOT\na\n\"\"\"\nEOT"))
+			Expect(quoteValue("EOT\n EOT1\na\"")).To(Equal("<<<EOT2\nEOT\n EOT1\na\"\nEOT2"))
+		})
+	})
+})
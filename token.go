@@ -32,7 +32,9 @@ type token interface {
 	getType() tokenType
 }
 
-type newLineToken struct{}
+type newLineToken struct {
+	value string
+}
 
 func (t *newLineToken) getType() tokenType {
 	return newLineTokType
@@ -46,13 +48,17 @@ func (t *spaceToken) getType() tokenType {
 	return spaceTokType
 }
 
-type sepToken struct{}
+type sepToken struct {
+	value string
+}
 
 func (t *sepToken) getType() tokenType {
 	return sepTokType
 }
 
-type commentToken struct{}
+type commentToken struct {
+	value string
+}
 
 func (t *commentToken) getType() tokenType {
 	return commentTokType
@@ -86,3 +92,16 @@ func stringValue(tok token) string {
 		return ""
 	}
 }
+
+func rawValue(tok token) string {
+	switch t := tok.(type) {
+	case *newLineToken:
+		return t.value
+	case *sepToken:
+		return t.value
+	case *commentToken:
+		return t.value
+	default:
+		return stringValue(tok)
+	}
+}
@@ -0,0 +1,85 @@
+package ini
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Byte order mark starting some UTF-8 files
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// Folds the case of an identifier, so that the identifiers differing only
+// by case, like Σ, σ and the final ς, or K and the Kelvin sign, are equal.
+// The lower case is kept for the letters having a single one
+func foldCase(s string) string {
+	return strings.Map(func(r rune) rune {
+		return unicode.ToLower(unicode.ToUpper(r))
+	}, s)
+}
+
+// Returns a reader of the content as UTF-8, without its byte order mark,
+// and true if a UTF-8 byte order mark was skipped. UTF-16 content is
+// detected by its byte order mark, or by the zero bytes of its first
+// ASCII character, and converted if detectUTF16 is set
+func newTextReader(rd io.Reader, detectUTF16 bool) (io.Reader, bool) {
+	br := bufio.NewReader(rd)
+	head, _ := br.Peek(3)
+	switch {
+	case bytes.HasPrefix(head, utf8BOM):
+		_, _ = br.Discard(len(utf8BOM))
+		return br, true
+	case !detectUTF16 || len(head) < 2:
+	case head[0] == 0xFF && head[1] == 0xFE:
+		_, _ = br.Discard(2)
+		return &utf16Reader{rd: br, order: binary.LittleEndian}, false
+	case head[0] == 0xFE && head[1] == 0xFF:
+		_, _ = br.Discard(2)
+		return &utf16Reader{rd: br, order: binary.BigEndian}, false
+	case head[0] != 0 && head[0] < utf8.RuneSelf && head[1] == 0:
+		return &utf16Reader{rd: br, order: binary.LittleEndian}, false
+	case head[0] == 0 && head[1] != 0 && head[1] < utf8.RuneSelf:
+		return &utf16Reader{rd: br, order: binary.BigEndian}, false
+	}
+	return br, false
+}
+
+// Reads UTF-16 content as UTF-8. Unpaired surrogates and a trailing
+// odd byte are read as the replacement character
+type utf16Reader struct {
+	rd    *bufio.Reader
+	order binary.ByteOrder
+	buf   []byte
+}
+
+func (r *utf16Reader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		var unit [2]byte
+		n, err := io.ReadFull(r.rd, unit[:])
+		if n == 0 {
+			return 0, err
+		}
+		c := utf8.RuneError
+		if n == len(unit) {
+			c = rune(r.order.Uint16(unit[:]))
+		}
+		if high := c; utf16.IsSurrogate(high) {
+			c = utf8.RuneError
+			if next, err := r.rd.Peek(len(unit)); err == nil {
+				if decoded := utf16.DecodeRune(high, rune(r.order.Uint16(next))); decoded != utf8.RuneError {
+					_, _ = r.rd.Discard(len(unit))
+					c = decoded
+				}
+			}
+		}
+		r.buf = utf8.AppendRune(r.buf, c)
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
@@ -0,0 +1,116 @@
+package ini
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing/fstest"
+	"unicode/utf16"
+)
+
+var _ = Describe("Unicode", func() {
+	encodeUTF16 := func(s string, order binary.ByteOrder, bom bool) string {
+		var units []uint16
+		if bom {
+			units = append(units, 0xFEFF)
+		}
+		units = append(units, utf16.Encode([]rune(s))...)
+		b := make([]byte, 2*len(units))
+		for i, unit := range units {
+			order.PutUint16(b[2*i:], unit)
+		}
+		return string(b)
+	}
+
+	It("should fold the case of identifiers", func() {
+		Expect(foldCase("ΟΔΟΣ")).To(Equal(foldCase("οδος")))
+		Expect(foldCase("Key")).To(Equal("key"))
+		Expect(foldCase("Straße")).To(Equal("straße"))
+	})
+
+	It("should decode Unicode sections and keys", func() {
+		var c Config
+		d := NewDecoder(strings.NewReader("[Straße]\nΚλειδί = värde 日本\n[ΟΔΟΣ]\nA = 1\n[οδος]\nb = 2\n"))
+		d.IdRegexp(`^\pL[\pL\pN_]*$`)
+		err := d.Decode(&c)
+		Expect(err).To(BeNil())
+		Expect(c["straße"]).To(Equal(map[string]string{"κλειδί": "värde 日本"}))
+		Expect(c["οδοσ"]).To(Equal(map[string]string{"a": "1", "b": "2"}))
+		_, ok := d.Position("STRASSE", "κλειδί")
+		Expect(ok).To(BeFalse())
+		pos, ok := d.Position("Straße", "ΚΛΕΙΔΊ")
+		Expect(ok).To(BeTrue())
+		Expect(pos.String()).To(Equal("2:1"))
+	})
+
+	It("should skip the UTF-8 byte order mark", func() {
+		var c Config
+		err := NewDecoder(strings.NewReader("\xEF\xBB\xBF[section]\nfoo = bar\n")).Decode(&c)
+		Expect(err).To(BeNil())
+		Expect(c["section"]["foo"]).To(Equal("bar"))
+	})
+
+	It("should write the UTF-8 byte order mark of documents back", func() {
+		content := "\xEF\xBB\xBF[section]\r\nfoo = bar\r\n"
+		doc, err := ParseDocument(strings.NewReader(content))
+		Expect(err).To(BeNil())
+		Expect(doc.String()).To(Equal(content))
+		d := NewFileDecoder("php.ini")
+		d.FS(fstest.MapFS{"php.ini": {Data: []byte(content)}})
+		doc, err = d.DecodeDocument()
+		Expect(err).To(BeNil())
+		Expect(doc.Section("section").Key("foo").Value()).To(Equal("bar"))
+		Expect(doc.String()).To(Equal(content))
+		doc, err = ParseDocument(strings.NewReader("[section]\n"))
+		Expect(err).To(BeNil())
+		Expect(doc.String()).To(Equal("[section]\n"))
+	})
+
+	It("should read UTF-16 content when asked to", func() {
+		content := "[section]\r\nfoo = bär 😀\r\n"
+		inputs := []string{
+			encodeUTF16(content, binary.LittleEndian, true),
+			encodeUTF16(content, binary.BigEndian, true),
+			encodeUTF16(content, binary.LittleEndian, false),
+			encodeUTF16(content, binary.BigEndian, false),
+		}
+		for _, input := range inputs {
+			var c Config
+			d := NewDecoder(strings.NewReader(input))
+			d.DetectUTF16(true)
+			err := d.Decode(&c)
+			Expect(err).To(BeNil())
+			Expect(c["section"]["foo"]).To(Equal("bär 😀"))
+		}
+		var c Config
+		err := NewDecoder(strings.NewReader(inputs[0])).Decode(&c)
+		Expect(err).NotTo(BeNil())
+	})
+
+	It("should read unpaired surrogates and odd bytes as replacement characters", func() {
+		input := encodeUTF16("a", binary.LittleEndian, true) + "\x00\xD8b\x00c"
+		rd, bom := newTextReader(strings.NewReader(input), true)
+		Expect(bom).To(BeFalse())
+		b, err := io.ReadAll(rd)
+		Expect(err).To(BeNil())
+		Expect(string(b)).To(Equal("a�b�"))
+	})
+
+	It("should read included UTF-16 files", func() {
+		fsys := fstest.MapFS{
+			"main.ini":  {Data: []byte("[section]\n!include extra.ini\n")},
+			"extra.ini": {Data: []byte(encodeUTF16("foo = bar\n", binary.LittleEndian, true))},
+		}
+		var c Config
+		d := NewFileDecoder("main.ini")
+		d.FS(fsys)
+		d.Includes(true)
+		d.DetectUTF16(true)
+		err := d.Decode(&c)
+		Expect(err).To(BeNil())
+		Expect(c["section"]["foo"]).To(Equal("bar"))
+	})
+})
@@ -0,0 +1,461 @@
+package ini
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Error returned when a value cannot be decoded
+type DecodeError struct {
+	Section string
+	Key     string
+	Value   string
+	Err     error
+}
+
+func (e *DecodeError) Error() string {
+	if e.Key == "" {
+		return fmt.Sprintf("Decode error at [%s]. %s", e.Section, e.Err)
+	}
+	return fmt.Sprintf("Decode error at [%s] %s = %s. %s", e.Section, e.Key, e.Value, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Implemented by types which can decode themselves from an ini value
+type Unmarshaler interface {
+	UnmarshalINI(value string) error
+}
+
+var (
+	unmarshalerType     = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	errUnknownKey       = errors.New("Unknown key.")
+	errUnknownSection   = errors.New("Unknown section.")
+)
+
+// Decodes a parsed config into a value
+type unmarshaler struct {
+	strict          bool
+	disallowUnknown bool
+	converters      map[reflect.Type]ConverterFunc
+	lists           listConfig
+	repeated        map[string][]sectionValues
+	paths           map[string][]string
+	defaultSection  string
+}
+
+func (u *unmarshaler) unmarshal(conf config, r interface{}) error {
+	rv := reflect.ValueOf(r)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("Decode error. Expected a non-nil pointer, got %s.", reflect.TypeOf(r))
+	}
+	v := rv.Elem()
+	switch {
+	case v.Kind() == reflect.Interface && v.NumMethod() == 0:
+		v.Set(reflect.ValueOf(Config(conf)))
+		return nil
+	case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String:
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		for _, name := range sectionNames(conf) {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := u.unmarshalSection(name, u.section(conf, name), elem); err != nil {
+				return err
+			}
+			v.SetMapIndex(reflect.ValueOf(name).Convert(v.Type().Key()), elem)
+		}
+		return nil
+	case v.Kind() == reflect.Struct:
+		fields := structFields(v.Type())
+		for _, name := range sectionNames(conf) {
+			if name == "" {
+				if err := u.unmarshalFields(name, u.section(conf, name), v, globalFields(v.Type(), fields)); err != nil {
+					return err
+				}
+				continue
+			}
+			field, ok := matchField(fields, name)
+			if !ok {
+				found, err := u.unmarshalPath(name, u.path(name), u.section(conf, name), v)
+				if err != nil {
+					return err
+				}
+				if !found && u.disallowUnknown && name != u.defaultSection {
+					return &DecodeError{Section: name, Err: errUnknownSection}
+				}
+				continue
+			}
+			fv, err := decodedFieldByIndex(v, field.index)
+			if err != nil {
+				return &DecodeError{Section: name, Err: err}
+			}
+			if isRepeatedSection(fv.Type()) {
+				if err := u.unmarshalRepeated(name, conf, fv); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := u.unmarshalSection(name, u.section(conf, name), fv); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("Decode error. Cannot decode into %s, expected a struct or a map.", v.Type())
+	}
+}
+
+func (u *unmarshaler) section(conf config, name string) sectionValues {
+	return sectionValues{conf[name], u.lists[name]}
+}
+
+// Returns the parts of a section name, as in server.http
+// or remote "origin", which is named remote.origin
+func (u *unmarshaler) path(name string) []string {
+	if path, ok := u.paths[name]; ok {
+		return path
+	}
+	return strings.Split(name, ".")
+}
+
+// Decodes a section into the nested struct field or map entry named by
+// its path, so that [server.http] is decoded into Server.HTTP and
+// [remote "origin"] into the origin entry of a map field named Remote.
+// Returns false if the path does not match any field
+func (u *unmarshaler) unmarshalPath(name string, path []string, section sectionValues, v reflect.Value) (bool, error) {
+	if len(path) == 0 {
+		return true, u.unmarshalSection(name, section, v)
+	}
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	switch {
+	case v.Kind() == reflect.Struct:
+		field, ok := matchField(structFields(v.Type()), path[0])
+		if !ok {
+			return false, nil
+		}
+		fv, err := decodedFieldByIndex(v, field.index)
+		if err != nil {
+			return false, &DecodeError{Section: name, Err: err}
+		}
+		return u.unmarshalPath(name, path[1:], section, fv)
+	case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String && isSectionType(v.Type().Elem()):
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		key := reflect.ValueOf(path[0]).Convert(v.Type().Key())
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if existing := v.MapIndex(key); existing.IsValid() {
+			elem.Set(existing)
+		}
+		found, err := u.unmarshalPath(name, path[1:], section, elem)
+		if found && err == nil {
+			v.SetMapIndex(key, elem)
+		}
+		return found, err
+	}
+	return false, nil
+}
+
+// Returns true for slices of structs or maps,
+// into which repeated sections are decoded
+func isRepeatedSection(t reflect.Type) bool {
+	t = indirectType(t)
+	return t.Kind() == reflect.Slice && !decodesItself(t) && isSectionType(t.Elem())
+}
+
+// Decodes each occurrence of a repeated section into an item of
+// a slice. A section given once is decoded into a single item
+func (u *unmarshaler) unmarshalRepeated(name string, conf config, v reflect.Value) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	occurrences, ok := u.repeated[name]
+	if !ok {
+		occurrences = []sectionValues{u.section(conf, name)}
+	}
+	slice := reflect.MakeSlice(v.Type(), len(occurrences), len(occurrences))
+	for i, occurrence := range occurrences {
+		if err := u.unmarshalSection(name, occurrence, slice.Index(i)); err != nil {
+			return err
+		}
+	}
+	v.Set(slice)
+	return nil
+}
+
+func (u *unmarshaler) unmarshalSection(name string, section sectionValues, v reflect.Value) error {
+	values := section.values
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	switch {
+	case v.Kind() == reflect.Interface && v.NumMethod() == 0:
+		section := make(map[string]string, len(values))
+		for key, value := range values {
+			section[key] = value
+		}
+		v.Set(reflect.ValueOf(section))
+	case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String:
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		for _, key := range keyNames(values) {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := u.unmarshalValue(name, key, section, elem); err != nil {
+				return err
+			}
+			v.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), elem)
+		}
+	case v.Kind() == reflect.Struct:
+		return u.unmarshalFields(name, section, v, structFields(v.Type()))
+	default:
+		return &DecodeError{Section: name, Err: fmt.Errorf("Cannot decode a section into %s.", v.Type())}
+	}
+	return nil
+}
+
+// Decodes the keys of a section into the matching fields of a struct
+func (u *unmarshaler) unmarshalFields(name string, section sectionValues, v reflect.Value, fields []fieldInfo) error {
+	for _, key := range keyNames(section.values) {
+		field, ok := matchField(fields, key)
+		if !ok {
+			if u.disallowUnknown {
+				return &DecodeError{name, key, section.values[key], errUnknownKey}
+			}
+			continue
+		}
+		fv, err := decodedFieldByIndex(v, field.index)
+		if err != nil {
+			return &DecodeError{name, key, section.values[key], err}
+		}
+		if err := u.unmarshalValue(name, key, section, fv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the field of v at the given index, allocating the nil embedded
+// pointers it goes through, as encoding/json does. Pointers to unexported
+// embedded structs cannot be allocated
+func decodedFieldByIndex(v reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, fmt.Errorf("Cannot set embedded pointer to unexported struct %s.", v.Type().Elem())
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, nil
+}
+
+// Returns the fields of a struct which are not sections,
+// into which global keys are decoded
+func globalFields(t reflect.Type, fields []fieldInfo) []fieldInfo {
+	var global []fieldInfo
+	for _, field := range fields {
+		if !isSectionType(t.FieldByIndex(field.index).Type) {
+			global = append(global, field)
+		}
+	}
+	return global
+}
+
+func (u *unmarshaler) unmarshalValue(name string, key string, section sectionValues, v reflect.Value) error {
+	var err error
+	value := section.values[key]
+	if list, ok := section.lists[key]; ok {
+		err = u.setValues(v, list)
+	} else {
+		err = u.setValue(v, value)
+	}
+	if err != nil {
+		return &DecodeError{name, key, value, err}
+	}
+	return nil
+}
+
+// Sets a slice from all the values of a key, one item per value.
+// Other types are set from the last value
+func (u *unmarshaler) setValues(v reflect.Value, values []string) error {
+	if v.Kind() != reflect.Slice || u.converters[v.Type()] != nil || decodesItself(v.Type()) {
+		return u.setValue(v, values[len(values)-1])
+	}
+	return u.setItems(v, values)
+}
+
+func (u *unmarshaler) setItems(v reflect.Value, items []string) error {
+	slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+	for i, item := range items {
+		if err := u.setValue(slice.Index(i), item); err != nil {
+			return err
+		}
+	}
+	v.Set(slice)
+	return nil
+}
+
+func decodesItself(t reflect.Type) bool {
+	pt := reflect.PtrTo(t)
+	return pt.Implements(unmarshalerType) || pt.Implements(textUnmarshalerType)
+}
+
+// Sets v from its string representation, using in order the converter
+// registered for its type, its ini.Unmarshaler or encoding.TextUnmarshaler
+// implementation, or the built-in conversions. Slices are decoded from
+// comma separated lists. Unless strict, empty strings are decoded as zero
+// values and decimal numbers are truncated when decoded to integers
+func (u *unmarshaler) setValue(v reflect.Value, value string) error {
+	if convert, ok := u.converters[v.Type()]; ok {
+		return convertValue(v, convert, value)
+	}
+	if v.Kind() == reflect.Ptr {
+		elem := reflect.New(v.Type().Elem())
+		if err := u.setValue(elem.Elem(), value); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+	if v.CanAddr() {
+		switch m := v.Addr().Interface().(type) {
+		case Unmarshaler:
+			return m.UnmarshalINI(value)
+		case encoding.TextUnmarshaler:
+			return m.UnmarshalText([]byte(value))
+		}
+	}
+	if value == "" && !u.strict && v.Kind() != reflect.String {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	if convert, ok := builtinConverters[v.Type()]; ok {
+		return convertValue(v, convert, value)
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Interface:
+		if v.NumMethod() > 0 {
+			return fmt.Errorf("Cannot decode into %s.", v.Type())
+		}
+		v.Set(reflect.ValueOf(value))
+	case reflect.Bool:
+		b, err := ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(value, 0, v.Type().Bits())
+		if err != nil && !u.strict {
+			if f, ferr := strconv.ParseFloat(value, 64); ferr == nil && !v.OverflowInt(int64(f)) {
+				i, err = int64(f), nil
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("Cannot convert to %s.", v.Type())
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, err := strconv.ParseUint(value, 0, v.Type().Bits())
+		if err != nil && !u.strict {
+			if f, ferr := strconv.ParseFloat(value, 64); ferr == nil && f >= 0 && !v.OverflowUint(uint64(f)) {
+				i, err = uint64(f), nil
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("Cannot convert to %s.", v.Type())
+		}
+		v.SetUint(i)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("Cannot convert to %s.", v.Type())
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		return u.setItems(v, splitList(value))
+	default:
+		return fmt.Errorf("Cannot decode into %s.", v.Type())
+	}
+	return nil
+}
+
+func convertValue(v reflect.Value, convert ConverterFunc, value string) error {
+	result, err := convert(value)
+	if err != nil {
+		return err
+	}
+	rv := reflect.ValueOf(result)
+	if !rv.IsValid() || rv.Kind() != v.Kind() || !rv.Type().ConvertibleTo(v.Type()) {
+		return fmt.Errorf("Converter returned %T, expected %s.", result, v.Type())
+	}
+	v.Set(rv.Convert(v.Type()))
+	return nil
+}
+
+// Finds the field for the given key, by tag or field name ignoring case,
+// then ignoring underscores and dashes, so that max_execution_time
+// matches a field named MaxExecutionTime
+func matchField(fields []fieldInfo, name string) (fieldInfo, bool) {
+	for _, field := range fields {
+		if strings.EqualFold(field.name, name) {
+			return field, true
+		}
+	}
+	simplified := simplifyName(name)
+	for _, field := range fields {
+		if strings.EqualFold(simplifyName(field.name), simplified) {
+			return field, true
+		}
+	}
+	return fieldInfo{}, false
+}
+
+func simplifyName(name string) string {
+	return strings.NewReplacer("_", "", "-", "").Replace(name)
+}
+
+func sectionNames(conf config) []string {
+	names := make([]string, 0, len(conf))
+	for name := range conf {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func keyNames(values map[string]string) []string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,288 @@
+package ini
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"time"
+)
+
+type logLevel int
+
+func (l *logLevel) UnmarshalINI(value string) error {
+	switch value {
+	case "debug":
+		*l = 0
+	case "error":
+		*l = 1
+	default:
+		return fmt.Errorf("Unknown level %s.", value)
+	}
+	return nil
+}
+
+func (l logLevel) MarshalINI() (string, error) {
+	return []string{"debug", "error"}[l], nil
+}
+
+type phpSection struct {
+	Engine           bool `ini:"engine"`
+	MaxExecutionTime int
+	MemoryLimit      string `ini:"memory_limit"`
+	Precision        *int8
+	Ratio            float32
+	Size             uint16
+	Other            interface{}
+}
+
+type phpConfig struct {
+	PHP     phpSection `ini:"php"`
+	Session *map[string]string
+	Any     interface{}
+}
+
+func unmarshalConfig(conf config, r interface{}, strict bool, disallowUnknown bool) error {
+	u := &unmarshaler{strict: strict, disallowUnknown: disallowUnknown}
+	return u.unmarshal(conf, r)
+}
+
+type EmbeddedCommon struct {
+	Host string
+}
+
+type embeddedBase struct {
+	Name string
+}
+
+var _ = Describe("unmarshal", func() {
+	conf := config{
+		"php": {
+			"engine":             "true",
+			"max_execution_time": "30",
+			"memory_limit":       "128M",
+			"precision":          "14",
+			"ratio":              "0.5",
+			"size":               "2.0",
+			"other":              "foo",
+		},
+		"session": {"save_path": "/tmp"},
+		"any":     {"foo": "bar"},
+	}
+
+	It("should decode into structs", func() {
+		var c phpConfig
+		err := unmarshalConfig(conf, &c, false, false)
+		Expect(err).To(BeNil())
+		Expect(c.PHP.Engine).To(BeTrue())
+		Expect(c.PHP.MaxExecutionTime).To(Equal(30))
+		Expect(c.PHP.MemoryLimit).To(Equal("128M"))
+		Expect(*c.PHP.Precision).To(Equal(int8(14)))
+		Expect(c.PHP.Ratio).To(Equal(float32(0.5)))
+		Expect(c.PHP.Size).To(Equal(uint16(2)))
+		Expect(c.PHP.Other).To(Equal("foo"))
+		Expect(*c.Session).To(Equal(map[string]string{"save_path": "/tmp"}))
+		Expect(c.Any).To(Equal(map[string]string{"foo": "bar"}))
+	})
+
+	It("should decode global keys into fields which are not sections", func() {
+		var c struct {
+			Name    string
+			Port    int
+			Section struct{ Name string }
+		}
+		global := config{"": {"name": "app", "port": "80", "section": "foo"}, "section": {"name": "bar"}}
+		err := unmarshalConfig(global, &c, false, false)
+		Expect(err).To(BeNil())
+		Expect(c.Name).To(Equal("app"))
+		Expect(c.Port).To(Equal(80))
+		Expect(c.Section.Name).To(Equal("bar"))
+		err = unmarshalConfig(global, &c, false, true)
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(HavePrefix("Decode error at [] section = foo."))
+	})
+
+	It("should decode into maps and interfaces", func() {
+		var m map[string]map[string]interface{}
+		err := unmarshalConfig(config{"section": {"foo": "bar"}}, &m, false, false)
+		Expect(err).To(BeNil())
+		Expect(m).To(Equal(map[string]map[string]interface{}{"section": {"foo": "bar"}}))
+		var i interface{}
+		err = unmarshalConfig(config{"section": {"foo": "bar"}}, &i, false, false)
+		Expect(err).To(BeNil())
+		Expect(i).To(Equal(Config{"section": {"foo": "bar"}}))
+	})
+
+	It("should decode empty values as zero values unless strict", func() {
+		var c phpConfig
+		empty := config{"php": {"max_execution_time": ""}}
+		Expect(unmarshalConfig(empty, &c, false, false)).To(BeNil())
+		Expect(c.PHP.MaxExecutionTime).To(Equal(0))
+		err := unmarshalConfig(empty, &c, true, false)
+		Expect(err).NotTo(BeNil())
+	})
+
+	It("should report the failing section and key in strict mode", func() {
+		var c phpConfig
+		err := unmarshalConfig(config{"php": {"size": "2.5"}}, &c, true, false)
+		Expect(err).NotTo(BeNil())
+		var decodeErr *DecodeError
+		Expect(errors.As(err, &decodeErr)).To(BeTrue())
+		Expect(decodeErr.Section).To(Equal("php"))
+		Expect(decodeErr.Key).To(Equal("size"))
+		Expect(decodeErr.Value).To(Equal("2.5"))
+		Expect(err.Error()).To(Equal("Decode error at [php] size = 2.5. Cannot convert to uint16."))
+	})
+
+	It("should fail on invalid values", func() {
+		var c phpConfig
+		err := unmarshalConfig(config{"php": {"engine": "maybe"}}, &c, false, false)
+		Expect(err).NotTo(BeNil())
+		err = unmarshalConfig(config{"php": {"precision": "300"}}, &c, false, false)
+		Expect(err).NotTo(BeNil())
+		err = unmarshalConfig(config{"php": {"size": "-1"}}, &c, false, false)
+		Expect(err).NotTo(BeNil())
+	})
+
+	It("should fail on unknown keys when asked to", func() {
+		var c phpConfig
+		unknownKey := config{"php": {"foo": "bar"}}
+		Expect(unmarshalConfig(unknownKey, &c, false, false)).To(BeNil())
+		err := unmarshalConfig(unknownKey, &c, false, true)
+		Expect(errors.Is(err, errUnknownKey)).To(BeTrue())
+		unknownSection := config{"foo": {}}
+		Expect(unmarshalConfig(unknownSection, &c, false, false)).To(BeNil())
+		err = unmarshalConfig(unknownSection, &c, false, true)
+		Expect(errors.Is(err, errUnknownSection)).To(BeTrue())
+	})
+
+	It("should allocate nil embedded pointers", func() {
+		var c struct {
+			*EmbeddedCommon
+			Server struct {
+				*EmbeddedCommon
+				Port int
+			}
+		}
+		err := unmarshalConfig(config{"": {"host": "a"}, "server": {"host": "b", "port": "80"}}, &c, false, false)
+		Expect(err).To(BeNil())
+		Expect(c.Host).To(Equal("a"))
+		Expect(c.Server.Host).To(Equal("b"))
+		Expect(c.Server.Port).To(Equal(80))
+	})
+
+	It("should fail on nil pointers to unexported embedded structs", func() {
+		var c struct {
+			*embeddedBase
+			Server struct{ *embeddedBase }
+		}
+		err := unmarshalConfig(config{"server": {"name": "b"}}, &c, false, false)
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(Equal("Decode error at [server] name = b. Cannot set embedded pointer to unexported struct ini.embeddedBase."))
+		err = unmarshalConfig(config{"": {"name": "a"}}, &c, false, false)
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(HavePrefix("Decode error at [] name = a."))
+		c.embeddedBase = &embeddedBase{}
+		err = unmarshalConfig(config{"": {"name": "a"}}, &c, false, false)
+		Expect(err).To(BeNil())
+		Expect(c.Name).To(Equal("a"))
+	})
+
+	It("should fail on bad targets", func() {
+		var c phpConfig
+		Expect(unmarshalConfig(conf, c, false, false)).NotTo(BeNil())
+		var s string
+		Expect(unmarshalConfig(conf, &s, false, false)).NotTo(BeNil())
+	})
+
+	It("should decode rich value types", func() {
+		var c struct {
+			Section struct {
+				Timeout  time.Duration
+				Memory   ByteSize
+				Enabled  bool
+				Disabled bool
+				Names    []string
+				Ports    []int
+			}
+		}
+		err := unmarshalConfig(config{"section": {
+			"timeout":  "1m",
+			"memory":   "128M",
+			"enabled":  "On",
+			"disabled": "no",
+			"names":    "foo, bar",
+			"ports":    "80,443",
+		}}, &c, true, false)
+		Expect(err).To(BeNil())
+		Expect(c.Section.Timeout).To(Equal(time.Minute))
+		Expect(c.Section.Memory).To(Equal(128 * MegaByte))
+		Expect(c.Section.Enabled).To(BeTrue())
+		Expect(c.Section.Disabled).To(BeFalse())
+		Expect(c.Section.Names).To(Equal([]string{"foo", "bar"}))
+		Expect(c.Section.Ports).To(Equal([]int{80, 443}))
+	})
+
+	It("should use custom converters first", func() {
+		var c struct {
+			Section struct {
+				Name  string
+				Names []string
+			}
+		}
+		u := &unmarshaler{converters: map[reflect.Type]ConverterFunc{
+			reflect.TypeOf(""): func(value string) (interface{}, error) {
+				return strings.ToUpper(value), nil
+			},
+			reflect.TypeOf([]string{}): func(value string) (interface{}, error) {
+				return strings.Split(value, "|"), nil
+			},
+		}}
+		err := u.unmarshal(config{"section": {"name": "foo", "names": "a|b"}}, &c)
+		Expect(err).To(BeNil())
+		Expect(c.Section.Name).To(Equal("FOO"))
+		Expect(c.Section.Names).To(Equal([]string{"a", "b"}))
+	})
+
+	It("should report converter errors", func() {
+		var c struct{ Section struct{ Name string } }
+		u := &unmarshaler{converters: map[reflect.Type]ConverterFunc{
+			reflect.TypeOf(""): func(value string) (interface{}, error) {
+				return nil, fmt.Errorf("Bad name.")
+			},
+		}}
+		err := u.unmarshal(config{"section": {"name": "foo"}}, &c)
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(Equal("Decode error at [section] name = foo. Bad name."))
+		u.converters[reflect.TypeOf("")] = func(value string) (interface{}, error) {
+			return 42, nil
+		}
+		err = u.unmarshal(config{"section": {"name": "foo"}}, &c)
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(ContainSubstring("Converter returned int"))
+	})
+
+	It("should use Unmarshaler and TextUnmarshaler implementations", func() {
+		var c struct {
+			Log struct {
+				Level    logLevel
+				LevelPtr *logLevel
+				Server   net.IP
+			}
+		}
+		err := unmarshalConfig(config{"log": {"level": "error", "levelptr": "error", "server": "10.0.0.1"}}, &c, true, false)
+		Expect(err).To(BeNil())
+		Expect(c.Log.Level).To(Equal(logLevel(1)))
+		Expect(*c.Log.LevelPtr).To(Equal(logLevel(1)))
+		Expect(c.Log.Server.String()).To(Equal("10.0.0.1"))
+		err = unmarshalConfig(config{"log": {"level": "warning"}}, &c, true, false)
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(Equal("Decode error at [log] level = warning. Unknown level warning."))
+		err = unmarshalConfig(config{"log": {"server": "nope"}}, &c, true, false)
+		Expect(err).NotTo(BeNil())
+	})
+})